@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Answer overrides the probed result of one source directory.
+// It mirrors the questions asked in manualLink so that a directory can be linked unattended.
+type Answer struct {
+	Skip    bool                  `yaml:"skip" json:"skip"`       //do not link this directory at all
+	Name    string                `yaml:"name" json:"name"`       //show or movie name
+	LinkDir string                `yaml:"linkDir" json:"linkDir"` //link directory under dst
+	Season  string                `yaml:"season" json:"season"`   //season directory, "#" for none
+	Files   map[string]FileAnswer `yaml:"files" json:"files"`     //per-file overrides, keyed by source file name
+}
+
+// FileAnswer overrides the probed result of one file.
+type FileAnswer struct {
+	Skip    bool   `yaml:"skip" json:"skip"`
+	Episode string `yaml:"episode" json:"episode"`
+	Name    string `yaml:"name" json:"name"` //movie name, movie mode only
+}
+
+// answers are keyed by source directory path or by the directory name alone.
+var answers map[string]*Answer
+
+func loadAnswers(file string) error {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	//yaml is a superset of json, so both formats are accepted here.
+	a := make(map[string]*Answer)
+	if err := yaml.Unmarshal(data, &a); err != nil {
+		return fmt.Errorf("parse %s: %s", file, err.Error())
+	}
+
+	answers = a
+	return nil
+}
+
+func findAnswer(dir string) *Answer {
+	if answers == nil {
+		return nil
+	}
+
+	if answer, ok := answers[dir]; ok && answer != nil {
+		return answer
+	}
+
+	_, dirName := getSplitPath(dir)
+	if answer, ok := answers[dirName]; ok && answer != nil {
+		return answer
+	}
+
+	return nil
+}
+
+// answerLink is the non-interactive counterpart of manualLink.
+func answerLink(answer *Answer, videos, episodes, names []string, origLinkDir string) (newVideos, newEpisodes []string, linkDir string) {
+	videoName := origLinkDir
+	if answer.Name != "" {
+		videoName = answer.Name
+	}
+
+	linkDir = videoName
+	if answer.LinkDir != "" {
+		linkDir = answer.LinkDir
+	}
+
+	newVideos = make([]string, len(videos))
+	newEpisodes = make([]string, len(episodes))
+
+	if *mode == "anime" {
+		season := getProbedSeason(videos)
+		if answer.Season == "#" {
+			season = ""
+		} else if answer.Season != "" {
+			season = answer.Season
+		}
+
+		for i, name := range names {
+			_, ext := getExtName(name)
+			episode := episodes[i]
+
+			fileAnswer := answer.Files[name]
+			if fileAnswer.Episode != "" {
+				episode = fileAnswer.Episode
+			}
+			if fileAnswer.Skip {
+				episode = ""
+			}
+
+			//the answered season applies to regular episodes only
+			fileSeason := season
+			if probed, _ := getSplitPath(videos[i]); isSpecialDir(probed) {
+				fileSeason = probed
+			}
+
+			newVideos[i] = videoName + ext
+			if fileSeason != "" {
+				newVideos[i] = path.Join(fileSeason, newVideos[i])
+			}
+			newEpisodes[i] = episode
+		}
+	} else if *mode == "movie" {
+		single := getVideosCount(names) <= 1
+
+		for i, name := range names {
+			_, ext := getExtName(name)
+			fileAnswer := answer.Files[name]
+
+			if fileAnswer.Skip {
+				newVideos[i] = videos[i]
+				newEpisodes[i] = "$$$$$"
+				continue
+			}
+
+			if single {
+				newVideos[i] = videoName + ext
+				newEpisodes[i] = ""
+				continue
+			}
+
+			movieName := probeVideoName(name)
+			movieName, _ = getExtName(movieName)
+			if fileAnswer.Name != "" {
+				movieName = fileAnswer.Name
+			}
+
+			newVideos[i] = path.Join(movieName, movieName+ext)
+			newEpisodes[i] = ""
+		}
+	}
+
+	return
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/xsm1997/animeLinker/media"
+)
+
+// Companions are subtitles and external audio kept in subfolders of a release,
+// like Subs/, SC/, TC/, Fonts/, CDs/ or Audio/. Subfolders with videos of their own
+// are releases or specials, not companions.
+
+const (
+	subtitleDetectSize = 256 * 1024 //enough dialogues to tell the language of a subtitle
+)
+
+// findCompanions returns the files of otherSuffix in the subfolders of dir, relative to dir.
+func findCompanions(dir string) []string {
+	companions := make([]string, 0)
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		fmt.Printf("Cannot read dir %s. error: %s.\n", dir, err.Error())
+		return companions
+	}
+
+	for _, file := range files {
+		if !file.IsDir() {
+			continue
+		}
+
+		subDir := path.Join(dir, file.Name())
+		names := getVideosInDir(subDir)
+		if getVideosCount(names) > 0 {
+			continue
+		}
+
+		for _, name := range names {
+			companions = append(companions, path.Join(file.Name(), name))
+		}
+		for _, name := range findCompanions(subDir) {
+			companions = append(companions, path.Join(file.Name(), name))
+		}
+	}
+
+	return companions
+}
+
+// getEpisodeKey tells which episode a file is, its specials directory included,
+// so OVA 01 is no companion of episode 01.
+func getEpisodeKey(name, episode string) string {
+	parsed := parser.Parse(path.Base(name))
+	if parser.IsExtraDir(parsed.SpecialDir) {
+		episode = parsed.Special
+	}
+
+	return parsed.SpecialDir + "/" + strings.TrimLeft(episode, "0")
+}
+
+// getBigrams returns the pairs of adjacent letters of title, ignoring case and spaces.
+func getBigrams(title string) map[string]int {
+	runes := []rune(strings.ToLower(strings.Join(strings.Fields(title), "")))
+	bigrams := make(map[string]int)
+	for i := 0; i+1 < len(runes); i++ {
+		bigrams[string(runes[i:i+2])]++
+	}
+
+	return bigrams
+}
+
+// getTitleSimilarity compares the titles of two names, from 0 to 1.
+func getTitleSimilarity(name1, name2 string) float64 {
+	bigrams1 := getBigrams(probeTitle(name1))
+	bigrams2 := getBigrams(probeTitle(name2))
+
+	total := 0
+	common := 0
+	for bigram, count := range bigrams1 {
+		total += count
+		if count2 := bigrams2[bigram]; count2 < count {
+			common += count2
+		} else {
+			common += count
+		}
+	}
+	for _, count := range bigrams2 {
+		total += count
+	}
+
+	if total == 0 {
+		return 0
+	}
+
+	return 2 * float64(common) / float64(total)
+}
+
+// matchCompanion finds the video of a companion by its episode, and among videos
+// of the same episode by the title. It returns -1 when no video or several fit.
+func matchCompanion(companion string, videos, episodes []string) int {
+	_, companionName := getSplitPath(companion)
+	key := getEpisodeKey(companionName, parser.Parse(companionName).Episode)
+
+	best := -1
+	bestSimilarity := -1.0
+	tie := false
+	for i, video := range videos {
+		if getVideosCount([]string{video}) == 0 || getEpisodeKey(video, episodes[i]) != key {
+			continue
+		}
+
+		similarity := getTitleSimilarity(companionName, video)
+		if similarity > bestSimilarity {
+			best = i
+			bestSimilarity = similarity
+			tie = false
+		} else if similarity == bestSimilarity {
+			tie = true
+		}
+	}
+
+	if tie {
+		return -1
+	}
+
+	return best
+}
+
+// getLanguageExt returns the extension of a file in dir with its language code.
+// name may be in subfolders of dir. Without language tag in the name, the language is
+// taken from the folders, like SC/ or TC/, and at last from the content of the file.
+func getLanguageExt(dir, name string) string {
+	folders, base := getSplitPath(name)
+	_, ext := getExtName(base)
+	if parser.Parse(base).Language != "" {
+		return parser.LanguageExt(base)
+	}
+
+	for folders != "" {
+		var folder string
+		folders, folder = getSplitPath(folders)
+		if language := parser.NormalizeLanguage(folder); language != "" {
+			return parser.WithLanguage(ext, language)
+		}
+	}
+
+	return parser.WithLanguage(ext, detectLanguage(path.Join(dir, name)))
+}
+
+// detectLanguage reads the start of a text subtitle, or the tracks of an external audio,
+// to tell its language, or "".
+func detectLanguage(file string) string {
+	ext := strings.ToLower(path.Ext(file))
+	if ext == ".mka" || ext == ".mks" {
+		if !*probeMediaFlag {
+			return ""
+		}
+
+		info, err := media.Probe(file)
+		if err != nil {
+			return ""
+		}
+
+		languages := info.Languages(media.TrackAudio)
+		if ext == ".mks" {
+			languages = info.Languages(media.TrackSubtitle)
+		}
+		if len(languages) == 0 {
+			return ""
+		}
+		return parser.NormalizeLanguage(languages[0])
+	}
+
+	if ext != ".ass" && ext != ".ssa" && ext != ".srt" && ext != ".vtt" {
+		return ""
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	data, err := ioutil.ReadAll(io.LimitReader(f, subtitleDetectSize))
+	if err != nil {
+		return ""
+	}
+
+	return parser.DetectLanguage(data)
+}
+
+// addCompanions adds the companions in the subfolders of dir to the videos,
+// named like the video they belong to plus their language tag.
+// companionOf is the index of the video of each companion, -1 for the files of dir.
+func addCompanions(dir string, videos, newVideos, episodes []string, search bool) (_, _, _ []string, companionOf []int) {
+	count := len(videos)
+	companionOf = make([]int, count)
+	for i := range companionOf {
+		companionOf[i] = -1
+	}
+	if !search {
+		return videos, newVideos, episodes, companionOf
+	}
+
+	for _, companion := range findCompanions(dir) {
+		i := matchCompanion(companion, videos[:count], episodes[:count])
+		if i < 0 {
+			fmt.Printf("[COMPANION] %s fits no video, ignored.\n", companion)
+			continue
+		}
+
+		newName, _ := getExtName(newVideos[i])
+
+		videos = append(videos, companion)
+		newVideos = append(newVideos, newName+getLanguageExt(dir, companion))
+		episodes = append(episodes, episodes[i])
+		companionOf = append(companionOf, i)
+	}
+
+	return videos, newVideos, episodes, companionOf
+}
+
+// followCompanions names the companions like their videos, tags and edits included,
+// so players find them. Companions of videos not linked are not linked either.
+func followCompanions(newFilenames, newVideos, episodes []string, companionOf []int) {
+	for i, video := range companionOf {
+		if video < 0 {
+			continue
+		}
+
+		episodes[i] = episodes[video]
+		if newFilenames[video] == "(Not linking)" {
+			newFilenames[i] = newFilenames[video]
+			continue
+		}
+
+		name, _ := getExtName(newFilenames[video])
+		_, ext := getExtName(newVideos[i])
+		newFilenames[i] = name + ext
+	}
+}
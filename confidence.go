@@ -0,0 +1,31 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/xsm1997/animeLinker/release"
+)
+
+var confidenceFlag = flag.Float64("confidence", release.ConfidenceHigh, "ask to confirm directories probed with a lower confidence, above 1 to always ask")
+
+// dirConfidence keeps the lowest confidence of the decisions made for a directory.
+type dirConfidence struct {
+	score  float64
+	reason string
+}
+
+func newDirConfidence() *dirConfidence {
+	return &dirConfidence{score: release.ConfidenceHigh}
+}
+
+func (c *dirConfidence) lower(score float64, reason string) {
+	if score < c.score {
+		c.score = score
+		c.reason = reason
+	}
+}
+
+// sure tells if the probed names can be linked without asking.
+func (c *dirConfidence) sure() bool {
+	return c.score >= *confidenceFlag
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+
+	"github.com/xsm1997/animeLinker/release"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the content of the yaml config file.
+// Flags given on the command line take precedence over it.
+type Config struct {
+	Src  string `yaml:"src"`
+	Dst  string `yaml:"dst"`
+	Mode string `yaml:"mode"`
+	Rule string `yaml:"rule"`
+
+	LinkMode     string `yaml:"linkMode"`
+	LinkFallback string `yaml:"linkFallback"` //used by the auto link mode
+	OnCollision  string `yaml:"onCollision"`  //skip, suffix, overwrite or fail
+
+	DirRule      string `yaml:"dirRule"`
+	DefaultRules struct {
+		Anime string `yaml:"anime"`
+		Movie string `yaml:"movie"`
+	} `yaml:"defaultRules"`
+
+	VideoSuffix  ListConfig `yaml:"videoSuffix"`
+	OtherSuffix  ListConfig `yaml:"otherSuffix"`
+	DeleteRegex  ListConfig `yaml:"deleteRegex"`
+	DeleteChar   ListConfig `yaml:"deleteChar"`
+	SubtitleTags ListConfig `yaml:"subtitleTags"`
+
+	LanguageRules struct {
+		Replace []release.LanguageRule `yaml:"replace"`
+		Extend  []release.LanguageRule `yaml:"extend"`
+	} `yaml:"languageRules"` //subtitle language tags => ISO 639 codes
+	DefaultLanguage string `yaml:"defaultLanguage"` //subtitles in this language are flagged as default
+
+	SpecialsDir  string `yaml:"specialsDir"` //season directory of OVA, SP and recaps
+	SpecialRules struct {
+		Replace []release.SpecialRule `yaml:"replace"`
+		Extend  []release.SpecialRule `yaml:"extend"`
+	} `yaml:"specialRules"`
+
+	Categories map[string]CategoryConfig `yaml:"categories"` //torrent category => where and how to link it
+
+	ProbeMedia *bool `yaml:"probeMedia"` //read the headers of videos
+
+	VerifyCRC  *bool  `yaml:"verifyCRC"`
+	BadCRC     string `yaml:"badCRC"` //refuse or warn
+	CRCWorkers int    `yaml:"crcWorkers"`
+
+	Upgrade struct {
+		Enabled *bool    `yaml:"enabled"`
+		Groups  []string `yaml:"groups"` //preferred groups, best first
+	} `yaml:"upgrade"`
+}
+
+// ListConfig changes one of the built-in lists.
+// Items of Replace take the place of the built-in ones, items of Extend are appended.
+type ListConfig struct {
+	Replace []string `yaml:"replace"`
+	Extend  []string `yaml:"extend"`
+}
+
+// CategoryConfig tells the hook command how to link torrents of one category.
+type CategoryConfig struct {
+	Dst      string `yaml:"dst"`
+	Mode     string `yaml:"mode"`
+	Rule     string `yaml:"rule"`
+	LinkMode string `yaml:"linkMode"` //like relsym for libraries on another pool
+}
+
+var (
+	configFile = flag.String("config", "", "config file (default ~/.config/animelinker/config.yaml)")
+	config     Config
+)
+
+func getDefaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+
+	return path.Join(dir, "animelinker", "config.yaml")
+}
+
+func (l ListConfig) apply(list []string) []string {
+	if l.Replace != nil {
+		list = append([]string{}, l.Replace...)
+	}
+
+	return append(list, l.Extend...)
+}
+
+// loadConfig reads the config file. A missing default config file is not an error.
+func loadConfig() error {
+	file := *configFile
+	if file == "" {
+		file = getDefaultConfigPath()
+		if file == "" || !checkFileExists(file) {
+			return nil
+		}
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("parse %s: %s", file, err.Error())
+	}
+
+	return nil
+}
+
+// applyConfig puts the config into effect, after the flags are parsed.
+func applyConfig() error {
+	//flags given explicitly win over the config file
+	setFlags := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		setFlags[f.Name] = true
+	})
+
+	if !setFlags["src"] && config.Src != "" {
+		*sourceDir = config.Src
+	}
+	if !setFlags["dst"] && config.Dst != "" {
+		*destinationDir = config.Dst
+	}
+	if !setFlags["mode"] && config.Mode != "" {
+		*mode = config.Mode
+	}
+	if !setFlags["rule"] && config.Rule != "" {
+		*ruleFlag = config.Rule
+	}
+	if !setFlags["dir-rule"] && config.DirRule != "" {
+		*dirRuleFlag = config.DirRule
+	}
+	if !setFlags["link-mode"] && config.LinkMode != "" {
+		*linkModeFlag = config.LinkMode
+	}
+	if !setFlags["link-fallback"] && config.LinkFallback != "" {
+		*linkFallbackFlag = config.LinkFallback
+	}
+	if !setFlags["on-collision"] && config.OnCollision != "" {
+		*onCollisionFlag = config.OnCollision
+	}
+	if !setFlags["upgrade"] && config.Upgrade.Enabled != nil {
+		*upgradeFlag = *config.Upgrade.Enabled
+	}
+	if !setFlags["probe-media"] && config.ProbeMedia != nil {
+		*probeMediaFlag = *config.ProbeMedia
+	}
+	if !setFlags["verify-crc"] && config.VerifyCRC != nil {
+		*verifyCRCFlag = *config.VerifyCRC
+	}
+	if !setFlags["bad-crc"] && config.BadCRC != "" {
+		*badCRCFlag = config.BadCRC
+	}
+	if !setFlags["crc-workers"] && config.CRCWorkers > 0 {
+		*crcWorkersFlag = config.CRCWorkers
+	}
+
+	videoSuffix = config.VideoSuffix.apply(videoSuffix)
+	otherSuffix = config.OtherSuffix.apply(otherSuffix)
+	parser.DeleteRegex = config.DeleteRegex.apply(parser.DeleteRegex)
+	parser.DeleteChar = config.DeleteChar.apply(parser.DeleteChar)
+	parser.SubtitleTags = config.SubtitleTags.apply(parser.SubtitleTags)
+
+	if config.SpecialsDir != "" {
+		parser.SpecialsDir = config.SpecialsDir
+	}
+	if config.SpecialRules.Replace != nil {
+		parser.SpecialRules = append([]release.SpecialRule{}, config.SpecialRules.Replace...)
+	}
+	parser.SpecialRules = append(parser.SpecialRules, config.SpecialRules.Extend...)
+
+	if config.LanguageRules.Replace != nil {
+		parser.LanguageRules = append([]release.LanguageRule{}, config.LanguageRules.Replace...)
+	}
+	parser.LanguageRules = append(parser.LanguageRules, config.LanguageRules.Extend...)
+	parser.DefaultLanguage = config.DefaultLanguage
+
+	return parser.Compile()
+}
+
+// getDefaultRule returns the naming rule used when none is given.
+func getDefaultRule() string {
+	if *mode == "movie" {
+		if config.DefaultRules.Movie != "" {
+			return config.DefaultRules.Movie
+		}
+		return DefaultRuleMovie
+	}
+
+	if config.DefaultRules.Anime != "" {
+		return config.DefaultRules.Anime
+	}
+	return DefaultRuleAnime
+}
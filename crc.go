@@ -0,0 +1,293 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	BadCRCRefuse = "refuse" //do not link videos failing the check
+	BadCRCWarn   = "warn"   //link them with a warning
+
+	crcProgressInterval = 10 * time.Second
+)
+
+var (
+	verifyCRCFlag  = flag.Bool("verify-crc", false, "check the CRC32 of videos against the tag in their names before linking")
+	badCRCFlag     = flag.String("bad-crc", BadCRCRefuse, "what to do with videos failing the crc check: refuse or warn")
+	crcWorkersFlag = flag.Int("crc-workers", 4, "videos hashed at the same time by -verify-crc")
+
+	badCRCFiles []string //refused videos, reported at the end
+
+	crcCache      map[string]crcCacheEntry
+	crcCacheMutex sync.Mutex
+
+	//the parser takes all digit tags for dates, but they may be crcs too
+	digitCRCRegex = regexp.MustCompile(`[\[(]([0-9]{8})[\])]`)
+)
+
+// crcCacheEntry is the checksum of a file as it was when hashed.
+// Files are known by device and inode, so renamed and linked files stay cached.
+type crcCacheEntry struct {
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"mtime"` //unix nanoseconds
+	CRC32   string `json:"crc32"`
+}
+
+func getCRCCachePath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+
+	return path.Join(dir, "animelinker", "crc32.json")
+}
+
+// loadCRCCache reads the cache once. A missing or broken cache is empty.
+func loadCRCCache() {
+	if crcCache != nil {
+		return
+	}
+
+	crcCache = make(map[string]crcCacheEntry)
+	file := getCRCCachePath()
+	if file == "" {
+		return
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return
+	}
+
+	if err := json.Unmarshal(data, &crcCache); err != nil {
+		fmt.Printf("[WARNING] Cannot read crc cache %s: %s.\n", file, err.Error())
+		crcCache = make(map[string]crcCacheEntry)
+	}
+}
+
+func saveCRCCache() {
+	file := getCRCCachePath()
+	if file == "" {
+		return
+	}
+
+	data, err := json.Marshal(crcCache)
+	if err == nil {
+		err = os.MkdirAll(path.Dir(file), 0755)
+	}
+	if err == nil {
+		//a crash while writing keeps the old cache
+		err = ioutil.WriteFile(file+".tmp", data, 0644)
+	}
+	if err == nil {
+		err = os.Rename(file+".tmp", file)
+	}
+
+	if err != nil {
+		fmt.Printf("[WARNING] Cannot write crc cache %s: %s.\n", file, err.Error())
+	}
+}
+
+// countingWriter counts the bytes hashed, for the progress.
+type countingWriter struct {
+	count *int64
+}
+
+func (w countingWriter) Write(p []byte) (int, error) {
+	atomic.AddInt64(w.count, int64(len(p)))
+	return len(p), nil
+}
+
+// getFileCRC returns the CRC32 of file as upper case hex, from the cache if the file is unchanged.
+func getFileCRC(file string, hashed *int64) (crc string, cached bool, err error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return "", false, err
+	}
+
+	key := ""
+	if dev, ino, ok := getFileID(info); ok {
+		key = fmt.Sprintf("%d:%d", dev, ino)
+	}
+
+	crcCacheMutex.Lock()
+	entry, ok := crcCache[key]
+	crcCacheMutex.Unlock()
+	if key != "" && ok && entry.Size == info.Size() && entry.ModTime == info.ModTime().UnixNano() {
+		atomic.AddInt64(hashed, info.Size())
+		return entry.CRC32, true, nil
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return "", false, err
+	}
+	defer f.Close()
+
+	hash := crc32.NewIEEE()
+	buf := make([]byte, 1<<20)
+	if _, err := io.CopyBuffer(io.MultiWriter(hash, countingWriter{hashed}), f, buf); err != nil {
+		return "", false, err
+	}
+	crc = fmt.Sprintf("%08X", hash.Sum32())
+
+	if key != "" {
+		crcCacheMutex.Lock()
+		crcCache[key] = crcCacheEntry{Size: info.Size(), ModTime: info.ModTime().UnixNano(), CRC32: crc}
+		crcCacheMutex.Unlock()
+	}
+
+	return crc, false, nil
+}
+
+// verifyEntries hashes the videos with a crc tag in their names, a few at a time,
+// and refuses or warns about the ones not matching their tag.
+// A tag of digits only may be a date, so a video not matching it is not checked.
+func verifyEntries(entries []LinkEntry) {
+	jobs := make([]int, 0)
+	total := int64(0)
+	for i, entry := range entries {
+		_, name := getSplitPath(entry.Source)
+		if entry.Skipped || getVideosCount([]string{name}) == 0 || parser.Parse(name).CRC32 == "" && !digitCRCRegex.MatchString(name) {
+			continue
+		}
+
+		jobs = append(jobs, i)
+		if info, err := os.Stat(entry.Source); err == nil {
+			total += info.Size()
+		}
+	}
+
+	if len(jobs) == 0 {
+		return
+	}
+
+	loadCRCCache()
+
+	var hashed int64
+	done := 0
+	bad := make(map[int]bool)
+	var mutex sync.Mutex
+
+	//big files take minutes, show the bytes hashed meanwhile
+	stop := make(chan bool)
+	go func() {
+		ticker := time.NewTicker(crcProgressInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				mutex.Lock()
+				fmt.Printf("[CRC] %.1f/%.1f GB hashed\n", float64(atomic.LoadInt64(&hashed))/(1<<30), float64(total)/(1<<30))
+				mutex.Unlock()
+			}
+		}
+	}()
+
+	queue := make(chan int)
+	var wg sync.WaitGroup
+	workers := *crcWorkersFlag
+	if workers > len(jobs) {
+		workers = len(jobs)
+	}
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := range queue {
+				source := entries[i].Source
+				_, name := getSplitPath(source)
+				expected := parser.Parse(name).CRC32
+				crc, cached, err := getFileCRC(source, &hashed)
+
+				mutex.Lock()
+				done++
+				switch {
+				case err == nil && expected == "":
+					//a tag of digits only counts when it matches
+					tags := digitCRCRegex.FindAllStringSubmatch(name, -1)
+					expected = tags[len(tags)-1][1]
+					for _, tag := range tags {
+						if tag[1] == crc {
+							expected = crc
+						}
+					}
+					if expected == crc {
+						fmt.Printf("[CRC] %d/%d OK %s\n", done, len(jobs), name)
+					} else {
+						fmt.Printf("[CRC] %d/%d %s not checked, [%s] may be a date.\n", done, len(jobs), name, expected)
+					}
+				case err != nil:
+					bad[i] = true
+					fmt.Printf("[CRC] %d/%d %s: %s.\n", done, len(jobs), name, err.Error())
+				case !strings.EqualFold(crc, expected):
+					bad[i] = true
+					fmt.Printf("[CRC] %d/%d BAD %s: expected %s, got %s.\n", done, len(jobs), name, expected, crc)
+				case cached:
+					fmt.Printf("[CRC] %d/%d OK %s (cached)\n", done, len(jobs), name)
+				default:
+					fmt.Printf("[CRC] %d/%d OK %s\n", done, len(jobs), name)
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	for _, i := range jobs {
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+	close(stop)
+
+	saveCRCCache()
+
+	for _, i := range jobs {
+		if !bad[i] {
+			continue
+		}
+
+		if *badCRCFlag == BadCRCWarn {
+			fmt.Printf("[WARNING] %s may be corrupted, linking it anyway.\n", entries[i].Source)
+			continue
+		}
+
+		fmt.Printf("[SKIP] %s may be corrupted, not linked.\n", entries[i].Source)
+		entries[i].Skipped = true
+		badCRCFiles = append(badCRCFiles, entries[i].Source)
+		skipCompanions(entries, entries[i].Source)
+	}
+}
+
+// skipCompanions skips the subtitles and audio of a refused video, the ones in subfolders
+// and the ones beside it named like it, so no stray tracks are linked.
+func skipCompanions(entries []LinkEntry, video string) {
+	base, _ := getExtName(video)
+	for i, entry := range entries {
+		if entry.Skipped || entry.Source == video {
+			continue
+		}
+
+		if entry.Video == video || strings.HasPrefix(entry.Source, base+".") && getVideosCount([]string{entry.Source}) == 0 {
+			fmt.Printf("[SKIP] %s belongs to %s, not linked.\n", entry.Source, video)
+			entries[i].Skipped = true
+		}
+	}
+}
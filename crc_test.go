@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestVerifyEntries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "animelinker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	//keep the cache out of the home directory
+	for _, key := range []string{"XDG_CACHE_HOME", "HOME"} {
+		defer os.Setenv(key, os.Getenv(key))
+		os.Setenv(key, dir)
+	}
+
+	data := []byte("episode")
+	crc := fmt.Sprintf("%08X", crc32.ChecksumIEEE(data))
+
+	in := []string{
+		`[Grp] Show - 01 [` + crc + `].mkv`,
+		`[Grp] Show - 02 [DEADBEEF].mkv`,
+		`[Grp] Show - 03.mkv`,
+		`[Grp] Show - 04 [DEADBEEF].sc.ass`,
+		`[Grp] Show - 02 [DEADBEEF].sc.ass`,
+		`Subs/[Grp] Show - 02.tc.ass`,
+		`[Grp] Show - 05 [20200101].mkv`,
+	}
+
+	out1 := []bool{false, true, false, false, true, true, false}
+
+	os.Mkdir(path.Join(dir, "Subs"), 0777)
+	entries := make([]LinkEntry, len(in))
+	for i, name := range in {
+		entries[i].Source = path.Join(dir, name)
+		ioutil.WriteFile(entries[i].Source, data, 0644)
+	}
+	//the subtitles of the bad video are not linked either
+	entries[5].Video = entries[1].Source
+
+	//the second run is cached
+	for run := 0; run < 2; run++ {
+		//read back what the first run saved
+		crcCache = nil
+		for i := range entries {
+			entries[i].Skipped = false
+		}
+		verifyEntries(entries)
+
+		for i, data := range in {
+			o1 := out1[i]
+			r1 := entries[i].Skipped
+
+			if r1 != o1 {
+				t.Errorf("Data %s: excepted %t, got %t", data, o1, r1)
+			}
+		}
+	}
+
+	//the saved cache knows the file
+	crcCache = nil
+	loadCRCCache()
+	var hashed int64
+	if _, cached, err := getFileCRC(entries[0].Source, &hashed); err != nil || !cached {
+		t.Errorf("Data %s: excepted %s, got %t", in[0], "cached", cached)
+	}
+
+	crcCache = nil
+	badCRCFiles = nil
+}
@@ -0,0 +1,29 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// getFileID returns the device and inode numbers of a file.
+func getFileID(info os.FileInfo) (dev, ino uint64, ok bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+
+	return uint64(stat.Dev), uint64(stat.Ino), true
+}
+
+// getLinkCount returns the number of hard links of a file.
+func getLinkCount(info os.FileInfo) (uint64, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+
+	return uint64(stat.Nlink), true
+}
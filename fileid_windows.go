@@ -0,0 +1,18 @@
+//go:build windows
+// +build windows
+
+package main
+
+import (
+	"os"
+)
+
+// getFileID is not supported on windows, os.FileInfo carries no file index there.
+func getFileID(info os.FileInfo) (dev, ino uint64, ok bool) {
+	return 0, 0, false
+}
+
+// getLinkCount is not supported on windows.
+func getLinkCount(info os.FileInfo) (uint64, bool) {
+	return 0, false
+}
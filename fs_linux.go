@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// checkWritable tells if files can be created in dir.
+func checkWritable(dir string) error {
+	//W_OK
+	if err := syscall.Access(dir, 2); err != nil {
+		return fmt.Errorf("cannot write to %s: %s", dir, err.Error())
+	}
+
+	return nil
+}
+
+// checkFreeSpace tells if the filesystem of dir has the inodes and bytes left.
+func checkFreeSpace(dir string, inodes, bytes uint64) error {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return err
+	}
+
+	//filesystems like btrfs have no inode limit and report 0 inodes
+	if stat.Files > 0 && uint64(stat.Ffree) < inodes {
+		return fmt.Errorf("%s has %d free inodes, %d are needed", dir, stat.Ffree, inodes)
+	}
+
+	if free := uint64(stat.Bavail) * uint64(stat.Bsize); free < bytes {
+		return fmt.Errorf("%s has %d bytes free, %d are needed", dir, free, bytes)
+	}
+
+	return nil
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+// checkWritable is only checked on linux, elsewhere linking reports the error.
+func checkWritable(dir string) error {
+	return nil
+}
+
+// checkFreeSpace is only checked on linux.
+func checkFreeSpace(dir string, inodes, bytes uint64) error {
+	return nil
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	GCList   = "list"
+	GCTrash  = "trash"
+	GCDelete = "delete"
+
+	TrashDirName = ".animelinker-trash"
+)
+
+var gcActionFlag = flag.String("action", GCList, "gc: what to do with orphaned files: list, trash or delete")
+
+// orphan is a destination file whose source is gone.
+type orphan struct {
+	path   string
+	reason string
+}
+
+func isMediaFile(name string) bool {
+	for _, suffix := range videoSuffix {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+
+	for _, suffix := range otherSuffix {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// findOrphans walks dst for media files whose source is gone.
+// Journaled links are checked against their source, the others by their link count.
+// The journal holds absolute paths, so dst must be absolute.
+func findOrphans(dst string) ([]orphan, error) {
+	records, err := readJournal(dst)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	links, _ := getLiveLinks(records)
+
+	//links journaled under another path are still known by their inode
+	inodes := make(map[uint64]JournalRecord)
+	for _, record := range links {
+		if record.Inode != 0 {
+			inodes[record.Inode] = record
+		}
+	}
+
+	orphans := make([]orphan, 0)
+	err = filepath.Walk(dst, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		//the journal, the trash and other hidden files
+		if strings.HasPrefix(info.Name(), ".") && file != dst {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if info.IsDir() || !isMediaFile(info.Name()) {
+			return nil
+		}
+
+		record, ok := links[file]
+		if !ok {
+			if _, ino, ok2 := getFileID(info); ok2 {
+				record, ok = inodes[ino]
+			}
+		}
+		if ok {
+			if _, err := os.Lstat(record.Source); os.IsNotExist(err) {
+				orphans = append(orphans, orphan{file, "source " + record.Source + " is gone"})
+			}
+			return nil
+		}
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			if _, err := os.Stat(file); os.IsNotExist(err) {
+				orphans = append(orphans, orphan{file, "dangling symlink"})
+			}
+			return nil
+		}
+
+		//copies are journaled, an unknown file with one link has no source left
+		if count, ok := getLinkCount(info); ok && count == 1 {
+			orphans = append(orphans, orphan{file, "no other link"})
+		}
+		return nil
+	})
+
+	return orphans, err
+}
+
+// gcDir lists, trashes or deletes the orphaned files of dst, and prunes the directories left empty.
+func gcDir(dst string) error {
+	dst = getAbsPath(dst)
+
+	if *gcActionFlag != GCList && *gcActionFlag != GCTrash && *gcActionFlag != GCDelete {
+		return fmt.Errorf("unknown action %s, must be list, trash or delete", *gcActionFlag)
+	}
+
+	orphans, err := findOrphans(dst)
+	if err != nil {
+		return err
+	}
+
+	if len(orphans) == 0 {
+		fmt.Println("No orphaned files.")
+		return nil
+	}
+
+	trashDir := path.Join(dst, TrashDirName, runID)
+	dirs := make([]string, 0)
+
+	for _, o := range orphans {
+		switch *gcActionFlag {
+		case GCList:
+			fmt.Printf("[ORPHAN] %s: %s\n", o.path, o.reason)
+			continue
+		case GCTrash:
+			target := path.Join(trashDir, strings.TrimPrefix(o.path, dst+"/"))
+			targetDir, _ := getSplitPath(target)
+			if err := os.MkdirAll(targetDir, 0777); err != nil {
+				return err
+			}
+
+			if err := os.Rename(o.path, target); err != nil {
+				return err
+			}
+
+			if err := writeJournal(JournalRecord{
+				Action:      JournalTrash,
+				Source:      target,
+				Destination: o.path,
+			}); err != nil {
+				return err
+			}
+			fmt.Printf("[TRASHED] %s\n", o.path)
+		case GCDelete:
+			if err := os.Remove(o.path); err != nil {
+				return err
+			}
+
+			if err := writeJournal(JournalRecord{
+				Action:      JournalDelete,
+				Destination: o.path,
+			}); err != nil {
+				return err
+			}
+			fmt.Printf("[DELETED] %s\n", o.path)
+		}
+
+		parent, _ := getSplitPath(o.path)
+		dirs = append(dirs, parent)
+	}
+
+	if *gcActionFlag == GCList {
+		fmt.Printf("%d orphaned files. Nothing removed, run with -action trash or -action delete.\n", len(orphans))
+		return nil
+	}
+
+	return pruneEmptyDirs(dst, dirs)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestFindOrphansRelativeLinks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "animelinker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	wd, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	//linked with a relative dst, collected with an absolute one
+	journalDir = "lib"
+	liveLinks = nil
+	defer func() {
+		journalFile.Close()
+		journalFile = nil
+		liveLinks = nil
+	}()
+
+	os.Mkdir("src", 0777)
+	in := []string{LinkCopy, LinkHard, LinkSym}
+
+	for _, data := range in {
+		source := path.Join("src", data+".mkv")
+		ioutil.WriteFile(source, []byte(data), 0644)
+
+		tx := &linkTx{linkMode: data}
+		if err := tx.linkFile(source, path.Join("lib", "Show", data+".mkv")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	orphans, err := findOrphans(path.Join(dir, "lib"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orphans) != 0 {
+		t.Errorf("Data %s: excepted %d orphans, got %v", "lib", 0, orphans)
+	}
+
+	os.Remove(path.Join("src", LinkCopy+".mkv"))
+
+	orphans, err = findOrphans(path.Join(dir, "lib"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orphans) != 1 || orphans[0].path != path.Join(dir, "lib", "Show", LinkCopy+".mkv") {
+		t.Errorf("Data %s: excepted %d orphans, got %v", "lib", 1, orphans)
+	}
+}
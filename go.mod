@@ -1,5 +1,8 @@
-module animeLinker
+module github.com/xsm1997/animeLinker
 
 go 1.16
 
-require github.com/dlclark/regexp2 v1.4.0 // indirect
+require (
+	github.com/dlclark/regexp2 v1.4.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// getHookTorrent returns the content path and category of the finished torrent.
+//
+// qBittorrent is configured to run `animelinker hook "%F" "%N" "%L" "%R"`,
+// Transmission passes TR_TORRENT_DIR, TR_TORRENT_NAME and TR_TORRENT_LABELS instead.
+func getHookTorrent() (content, category string) {
+	if flag.NArg() > 0 {
+		content = flag.Arg(0)
+		category = flag.Arg(2)
+
+		//%F may be empty on old qBittorrent versions
+		if content == "" {
+			content = flag.Arg(3)
+		}
+		return
+	}
+
+	dir := os.Getenv("TR_TORRENT_DIR")
+	name := os.Getenv("TR_TORRENT_NAME")
+	if dir != "" && name != "" {
+		content = path.Join(dir, name)
+	}
+
+	//labels are comma separated, the first one acts as category
+	labels := os.Getenv("TR_TORRENT_LABELS")
+	category = strings.TrimSpace(strings.Split(labels, ",")[0])
+
+	return
+}
+
+// setupHook points src, dst, mode and rule at the finished torrent.
+// It returns false if the torrent is not to be linked.
+func setupHook() bool {
+	content, category := getHookTorrent()
+	if content == "" {
+		fmt.Printf("usage: animelinker hook %s, or run from transmission\n", `"%F" "%N" "%L" "%R"`)
+		os.Exit(1)
+	}
+
+	categoryConfig, ok := config.Categories[category]
+	if !ok {
+		categoryConfig, ok = config.Categories["default"]
+	}
+
+	if ok {
+		if categoryConfig.Dst != "" {
+			*destinationDir = categoryConfig.Dst
+		}
+		if categoryConfig.Mode != "" {
+			*mode = categoryConfig.Mode
+		}
+		if categoryConfig.Rule != "" {
+			*ruleFlag = categoryConfig.Rule
+		}
+		if categoryConfig.LinkMode != "" {
+			*linkModeFlag = categoryConfig.LinkMode
+		}
+	}
+
+	if *destinationDir == "" {
+		//not an error, the client runs us for every torrent
+		fmt.Printf("No destination for category '%s', %s not linked.\n", category, content)
+		return false
+	}
+
+	*sourceDir = content
+	return true
+}
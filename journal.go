@@ -0,0 +1,405 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	JournalFileName = ".animelinker-journal.jsonl"
+
+	JournalLink  = "link"
+	JournalMkdir = "mkdir"
+	JournalUndo  = "undo"
+	//a link or directory removed when linking its directory failed
+	JournalRollback = "rollback"
+	//orphans moved to the trash or deleted by gc
+	JournalTrash  = "trash"
+	JournalDelete = "delete"
+)
+
+// JournalRecord is one line of the append-only journal kept in the destination root.
+type JournalRecord struct {
+	RunID       string    `json:"run"`
+	Time        time.Time `json:"time"`
+	Action      string    `json:"action"`
+	Source      string    `json:"source,omitempty"`
+	Destination string    `json:"destination,omitempty"`
+	Inode       uint64    `json:"inode,omitempty"`
+	LinkMode    string    `json:"linkMode,omitempty"` //hard, sym, relsym, reflink or copy
+	Replaced    string    `json:"replaced,omitempty"` //source of the link replaced by an upgrade or overwrite
+}
+
+var (
+	journalDir    string
+	journalFile   *os.File
+	liveLinks     map[string]JournalRecord //loaded by getJournaledLink
+	replacedLinks map[string]JournalRecord
+	runID         = time.Now().Format("20060102-150405") + "-" + strconv.Itoa(os.Getpid())
+)
+
+func getJournalPath(dir string) string {
+	return path.Join(dir, JournalFileName)
+}
+
+// getAbsPath returns file as an absolute path, so the journal does not depend on the working directory.
+func getAbsPath(file string) string {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return path.Clean(file)
+	}
+
+	return abs
+}
+
+// writeJournal appends record to the journal. A link that cannot be journaled is rolled back,
+// so the error is returned instead of exiting.
+func writeJournal(record JournalRecord) error {
+	if journalDir == "" {
+		return nil
+	}
+
+	if journalFile == nil {
+		err := os.MkdirAll(journalDir, 0777)
+		if err == nil {
+			journalFile, err = os.OpenFile(getJournalPath(journalDir), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+		}
+		if err != nil {
+			journalFile = nil
+			return fmt.Errorf("cannot open journal: %s", err.Error())
+		}
+	}
+
+	//links restored by undo keep their run
+	if record.RunID == "" {
+		record.RunID = runID
+	}
+	record.Time = time.Now()
+
+	data, _ := json.Marshal(record)
+	if _, err := journalFile.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("cannot write journal: %s", err.Error())
+	}
+
+	if liveLinks != nil {
+		applyLiveLink(liveLinks, replacedLinks, record)
+	}
+
+	return nil
+}
+
+// getJournaledLink returns the journal record of the link at dest, empty if it is not journaled.
+func getJournaledLink(dest string) JournalRecord {
+	if liveLinks == nil {
+		records, _ := readJournal(journalDir)
+		liveLinks, replacedLinks = getLiveLinks(records)
+	}
+
+	return liveLinks[getAbsPath(dest)]
+}
+
+// getJournaledSource returns the source of the link at dest, or "" if it is not journaled.
+func getJournaledSource(dest string) string {
+	return getJournaledLink(dest).Source
+}
+
+func journalLink(oldPath, newPath, linkMode, replaced string) error {
+	record := JournalRecord{
+		Action:      JournalLink,
+		Source:      getAbsPath(oldPath),
+		Destination: getAbsPath(newPath),
+		LinkMode:    linkMode,
+		Replaced:    replaced,
+	}
+
+	//the inode of a symlink itself, as checked by undo
+	if info, err := os.Lstat(newPath); err == nil {
+		_, record.Inode, _ = getFileID(info)
+	}
+
+	return writeJournal(record)
+}
+
+// mkdirAll works like os.MkdirAll, but journals every directory it creates.
+// It returns the created directories, parents first.
+func mkdirAll(dir string) ([]string, error) {
+	missing := make([]string, 0)
+	for d := dir; d != "" && d != "." && d != "/"; d, _ = getSplitPath(d) {
+		exists, err := statFileExists(d)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			break
+		}
+		missing = append(missing, d)
+	}
+
+	created := make([]string, 0, len(missing))
+	for i := len(missing) - 1; i >= 0; i-- {
+		if err := os.Mkdir(missing[i], 0777); err != nil && !os.IsExist(err) {
+			return created, err
+		}
+
+		created = append(created, missing[i])
+		if err := writeJournal(JournalRecord{
+			Action:      JournalMkdir,
+			Destination: getAbsPath(missing[i]),
+		}); err != nil {
+			return created, err
+		}
+	}
+
+	return created, nil
+}
+
+func readJournal(dir string) ([]JournalRecord, error) {
+	file, err := os.Open(getJournalPath(dir))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	records := make([]JournalRecord, 0)
+	lineScanner := bufio.NewScanner(file)
+	lineScanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for lineScanner.Scan() {
+		line := strings.TrimSpace(lineScanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var record JournalRecord
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			//a crash may leave a truncated last line
+			fmt.Printf("Ignoring bad journal line: %s.\n", err.Error())
+			continue
+		}
+		records = append(records, record)
+	}
+
+	return records, lineScanner.Err()
+}
+
+// lastRunID returns the latest run that created something and has not been undone.
+func lastRunID(records []JournalRecord) string {
+	undone := make(map[string]bool)
+	for _, record := range records {
+		if record.Action == JournalUndo {
+			undone[record.Source] = true
+		}
+	}
+
+	for i := len(records) - 1; i >= 0; i-- {
+		record := records[i]
+		if (record.Action == JournalLink || record.Action == JournalMkdir) && !undone[record.RunID] {
+			return record.RunID
+		}
+	}
+
+	return ""
+}
+
+// pruneEmptyDirs removes dirs and their parents when they are left empty,
+// deepest first, but never the destination root.
+func pruneEmptyDirs(root string, dirs []string) error {
+	root = path.Clean(root) + "/"
+	for _, d := range dirs {
+		for ; strings.HasPrefix(d, root); d, _ = getSplitPath(d) {
+			if exists, err := statFileExists(d); err != nil {
+				return err
+			} else if !exists {
+				continue
+			}
+			if empty, err := statDirEmpty(d); err != nil {
+				return err
+			} else if !empty {
+				break
+			}
+			if err := os.Remove(d); err != nil {
+				return err
+			}
+			fmt.Printf("[REMOVED] %s/\n", d)
+		}
+	}
+
+	return nil
+}
+
+// getLiveLinks returns the journal record of every link still in place, by destination,
+// and of the links they replaced. Links of undone runs, rolled back or collected by gc are left out.
+func getLiveLinks(records []JournalRecord) (links, replaced map[string]JournalRecord) {
+	undone := make(map[string]bool)
+	for _, record := range records {
+		if record.Action == JournalUndo {
+			undone[record.Source] = true
+		}
+	}
+
+	links = make(map[string]JournalRecord)
+	replaced = make(map[string]JournalRecord)
+	for _, record := range records {
+		if !undone[record.RunID] {
+			applyLiveLink(links, replaced, record)
+		}
+	}
+
+	return links, replaced
+}
+
+// applyLiveLink updates the live links with one journal record.
+// A link replaced by an upgrade comes back when the upgrade is rolled back.
+func applyLiveLink(links, replaced map[string]JournalRecord, record JournalRecord) {
+	switch record.Action {
+	case JournalLink:
+		if old, ok := links[record.Destination]; ok {
+			replaced[record.Destination] = old
+		}
+		links[record.Destination] = record
+	case JournalRollback:
+		delete(links, record.Destination)
+		if old, ok := replaced[record.Destination]; ok {
+			links[record.Destination] = old
+			delete(replaced, record.Destination)
+		}
+	case JournalTrash, JournalDelete:
+		delete(links, record.Destination)
+	}
+}
+
+// findReplacedLink returns the journal record of the link replaced by record, among the records before it.
+func findReplacedLink(records []JournalRecord, record JournalRecord, undone map[string]bool) (JournalRecord, bool) {
+	if record.Replaced == "" {
+		return JournalRecord{}, false
+	}
+
+	for i := len(records) - 1; i >= 0; i-- {
+		old := records[i]
+		if old.Action == JournalLink && old.Destination == record.Destination && old.Source == record.Replaced && !undone[old.RunID] {
+			return old, true
+		}
+	}
+
+	return JournalRecord{}, false
+}
+
+// restoreLink links the source of record to its destination again, renamed over the file there,
+// and journals it in the run of record.
+func restoreLink(record JournalRecord) error {
+	if _, err := os.Stat(record.Source); err != nil {
+		return err
+	}
+
+	dir, name := getSplitPath(record.Destination)
+	tempPath := path.Join(dir, "."+name+".animelinker-tmp")
+	os.Remove(tempPath)
+
+	if err := createLinkMode(record.LinkMode, record.Source, tempPath); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+
+	if err := os.Rename(tempPath, record.Destination); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+
+	if info, err := os.Lstat(record.Destination); err == nil {
+		_, record.Inode, _ = getFileID(info)
+	}
+	return writeJournal(record)
+}
+
+func undoRun(dir, id string) error {
+	dir = getAbsPath(dir)
+
+	records, err := readJournal(dir)
+	if err != nil {
+		return err
+	}
+
+	if id == "" {
+		id = lastRunID(records)
+		if id == "" {
+			return fmt.Errorf("nothing to undo")
+		}
+	}
+
+	fmt.Printf("Undoing run %s.\n", id)
+
+	undone := make(map[string]bool)
+	for _, record := range records {
+		if record.Action == JournalUndo {
+			undone[record.Source] = true
+		}
+	}
+
+	dirs := make([]string, 0)
+	found := false
+
+	for i := len(records) - 1; i >= 0; i-- {
+		record := records[i]
+		if record.RunID != id {
+			continue
+		}
+
+		switch record.Action {
+		case JournalLink:
+			found = true
+
+			info, err := os.Lstat(record.Destination)
+			if os.IsNotExist(err) {
+				continue
+			} else if err != nil {
+				return err
+			}
+
+			//never remove a file that was replaced after linking
+			if _, ino, ok := getFileID(info); ok && record.Inode != 0 && ino != record.Inode {
+				fmt.Printf("[SKIP] %s was replaced since linking.\n", record.Destination)
+				continue
+			}
+
+			//an upgrade or overwrite puts the link it replaced back
+			if old, ok := findReplacedLink(records[:i], record, undone); ok {
+				if err := restoreLink(old); err != nil {
+					fmt.Printf("Cannot restore %s: %s.\n", old.Source, err.Error())
+				} else {
+					fmt.Printf("[RESTORED] %s => %s\n", old.Destination, old.Source)
+					continue
+				}
+			}
+
+			if err := os.Remove(record.Destination); err != nil {
+				return err
+			}
+			fmt.Printf("[REMOVED] %s\n", record.Destination)
+
+			parent, _ := getSplitPath(record.Destination)
+			dirs = append(dirs, parent)
+		case JournalMkdir:
+			found = true
+			dirs = append(dirs, record.Destination)
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("run %s not found in journal", id)
+	}
+
+	if err := pruneEmptyDirs(dir, dirs); err != nil {
+		return err
+	}
+
+	return writeJournal(JournalRecord{
+		Action: JournalUndo,
+		Source: id,
+	})
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const (
+	CollisionSkip      = "skip"
+	CollisionSuffix    = "suffix"
+	CollisionOverwrite = "overwrite"
+	CollisionFail      = "fail"
+)
+
+var onCollisionFlag = flag.String("on-collision", CollisionSuffix, "when a destination file exists: skip, suffix, overwrite or fail")
+
+// getCommonDir returns the deepest directory containing all destinations.
+func getCommonDir(entries []LinkEntry) string {
+	common := ""
+	for _, entry := range entries {
+		if entry.Skipped {
+			continue
+		}
+
+		dir, _ := getSplitPath(entry.Destination)
+		if common == "" {
+			common = dir
+			continue
+		}
+
+		for common != "" && dir != common && !strings.HasPrefix(dir, common+"/") {
+			common, _ = getSplitPath(common)
+		}
+	}
+
+	return common
+}
+
+// checkLinked tells if newPath already is a link of oldPath, made with any link mode.
+// Copies and reflinks have their own inode, they are recognized by the journal,
+// and by their size and modification time in case the source changed since.
+func checkLinked(oldPath, newPath string) bool {
+	oldInfo, err := os.Stat(oldPath)
+	if err != nil {
+		return false
+	}
+
+	newInfo, err := os.Lstat(newPath)
+	if err != nil {
+		return false
+	}
+
+	if newInfo.Mode()&os.ModeSymlink != 0 {
+		//sym and relsym links point to the source
+		target, err := os.Readlink(newPath)
+		if err != nil {
+			return false
+		}
+		if !path.IsAbs(target) {
+			dir, _ := getSplitPath(newPath)
+			target = path.Join(dir, target)
+		}
+
+		targetInfo, err := os.Stat(target)
+		return err == nil && os.SameFile(oldInfo, targetInfo)
+	}
+
+	if os.SameFile(oldInfo, newInfo) {
+		return true
+	}
+
+	record := getJournaledLink(newPath)
+	if record.Source != getAbsPath(oldPath) || (record.LinkMode != LinkCopy && record.LinkMode != LinkReflink) {
+		return false
+	}
+
+	return newInfo.Mode().IsRegular() && newInfo.Size() == oldInfo.Size() && newInfo.ModTime().Equal(oldInfo.ModTime())
+}
+
+// createLink links oldPath to newPath with the link mode of tx, or of the flags.
+func (tx *linkTx) createLink(oldPath, newPath string) (string, error) {
+	if tx.linkMode != "" {
+		return tx.linkMode, createLinkMode(tx.linkMode, oldPath, newPath)
+	}
+
+	return createLink(oldPath, newPath)
+}
+
+// makeLink links oldPath to newPath with the link mode and journals it.
+func (tx *linkTx) makeLink(oldPath, newPath string) error {
+	linkMode, err := tx.createLink(oldPath, newPath)
+	if err != nil {
+		return err
+	}
+
+	tx.links = append(tx.links, newPath)
+	return journalLink(oldPath, newPath, linkMode, "")
+}
+
+// replaceLink replaces newPath with a link of oldPath.
+// The link is made beside it and renamed over it, so newPath never is missing.
+// The replaced file is kept by a hard link until the commit.
+func (tx *linkTx) replaceLink(oldPath, newPath string) error {
+	replaced := getJournaledSource(newPath)
+
+	dir, name := getSplitPath(newPath)
+	tempPath := path.Join(dir, "."+name+".animelinker-tmp")
+	backupPath := path.Join(dir, "."+name+".animelinker-old")
+	os.Remove(tempPath)
+	os.Remove(backupPath)
+
+	linkMode, err := tx.createLink(oldPath, tempPath)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Link(newPath, backupPath); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+
+	if err := os.Rename(tempPath, newPath); err != nil {
+		os.Remove(tempPath)
+		os.Remove(backupPath)
+		return err
+	}
+
+	tx.backups = append(tx.backups, backupPath)
+	tx.replaced = append(tx.replaced, newPath)
+	tx.links = append(tx.links, newPath)
+	return journalLink(oldPath, newPath, linkMode, replaced)
+}
+
+func (tx *linkTx) linkFile(oldPath, newPath string) error {
+	newPathDir, _ := getSplitPath(newPath)
+	if _, err := os.Stat(newPathDir); os.IsNotExist(err) {
+		fmt.Printf("dest %s not exists, creating.\n", newPathDir)
+		dirs, err := mkdirAll(newPathDir)
+		tx.dirs = append(tx.dirs, dirs...)
+		if err != nil {
+			return err
+		}
+	}
+
+	//Lstat, a dangling symlink is taken too
+	if _, err := os.Lstat(newPath); os.IsNotExist(err) {
+		return tx.makeLink(oldPath, newPath)
+	} else if err != nil {
+		return err
+	}
+
+	if checkLinked(oldPath, newPath) {
+		fmt.Printf("[SKIP] %s already linked.\n", newPath)
+		return nil
+	}
+
+	replaced := getJournaledSource(newPath)
+	if upgrade := checkUpgrade(replaced, oldPath); upgrade > 0 {
+		fmt.Printf("[UPGRADE] %s: %s => %s\n", newPath, replaced, oldPath)
+		return tx.replaceLink(oldPath, newPath)
+	} else if upgrade < 0 {
+		fmt.Printf("[SKIP] %s: a better release is linked.\n", newPath)
+		return nil
+	}
+
+	switch *onCollisionFlag {
+	case CollisionSkip:
+		fmt.Printf("[SKIP] %s exists.\n", newPath)
+		return nil
+	case CollisionFail:
+		return fmt.Errorf("%s exists", newPath)
+	case CollisionOverwrite:
+		fmt.Printf("[OVERWRITE] %s\n", newPath)
+		return tx.replaceLink(oldPath, newPath)
+	}
+
+	newPathBase, extName := getExtName(newPath)
+	for i := 2; i <= 99; i++ {
+		newPath2 := newPathBase + " (" + strconv.Itoa(i) + ")" + extName
+
+		if _, err := os.Lstat(newPath2); os.IsNotExist(err) {
+			return tx.makeLink(oldPath, newPath2)
+		} else if err != nil {
+			return err
+		}
+
+		//linked with a suffix by an earlier run
+		if checkLinked(oldPath, newPath2) {
+			fmt.Printf("[SKIP] %s already linked.\n", newPath2)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%s exists, and so do its suffixes up to (99)", newPath)
+}
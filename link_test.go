@@ -0,0 +1,125 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestCheckLinked(t *testing.T) {
+	dir, err := ioutil.TempDir("", "animelinker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	journalDir = dir
+	liveLinks = nil
+	defer func() {
+		journalFile.Close()
+		journalFile = nil
+		liveLinks = nil
+	}()
+
+	source := path.Join(dir, "source.mkv")
+	other := path.Join(dir, "other.mkv")
+	ioutil.WriteFile(source, []byte("source"), 0644)
+	ioutil.WriteFile(other, []byte("other"), 0644)
+
+	in := []string{LinkHard, LinkSym, LinkRelSym, LinkCopy}
+
+	for _, data := range in {
+		newPath := path.Join(dir, data+".mkv")
+		tx := &linkTx{linkMode: data}
+		if err := tx.makeLink(source, newPath); err != nil {
+			t.Fatal(err)
+		}
+
+		if !checkLinked(source, newPath) {
+			t.Errorf("Data %s: excepted %t, got %t", data, true, false)
+		}
+	}
+
+	//a copy not journaled is another file, even of the same size and time
+	copied := path.Join(dir, "copied.mkv")
+	if err := createLinkMode(LinkCopy, source, copied); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, data := range []string{other, copied} {
+		if checkLinked(source, data) {
+			t.Errorf("Data %s: excepted %t, got %t", data, false, true)
+		}
+	}
+}
+
+func TestLinkRollback(t *testing.T) {
+	dir, err := ioutil.TempDir("", "animelinker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	journalDir = dir
+	defer func() {
+		journalFile.Close()
+		journalFile = nil
+	}()
+
+	source := path.Join(dir, "source.mkv")
+	ioutil.WriteFile(source, []byte("source"), 0644)
+
+	tx := &linkTx{}
+	if err := tx.linkFile(source, path.Join(dir, "Show/S01/Show - 01.mkv")); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.linkFile(path.Join(dir, "missing.mkv"), path.Join(dir, "Show/S01/Show - 02.mkv")); err == nil {
+		t.Fatal("linking a missing file succeeded")
+	}
+	tx.rollback()
+
+	if checkFileExists(path.Join(dir, "Show")) {
+		t.Errorf("Data %s: excepted %t, got %t", "Show", false, true)
+	}
+}
+
+func TestReplaceLinkRollback(t *testing.T) {
+	dir, err := ioutil.TempDir("", "animelinker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	journalDir = dir
+	defer func() {
+		journalFile.Close()
+		journalFile = nil
+	}()
+
+	source := path.Join(dir, "source.mkv")
+	newPath := path.Join(dir, "Show - 01.mkv")
+	ioutil.WriteFile(source, []byte("new"), 0644)
+	ioutil.WriteFile(newPath, []byte("old"), 0644)
+
+	tx := &linkTx{}
+	if err := tx.replaceLink(source, newPath); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadFile(newPath); string(data) != "new" {
+		t.Errorf("Data %s: excepted %s, got %s", newPath, "new", data)
+	}
+
+	tx.rollback()
+	if data, _ := ioutil.ReadFile(newPath); string(data) != "old" {
+		t.Errorf("Data %s: excepted %s, got %s", newPath, "old", data)
+	}
+
+	files, _ := ioutil.ReadDir(dir)
+	for _, file := range files {
+		if strings.Contains(file.Name(), ".animelinker-") && file.Name() != JournalFileName {
+			t.Errorf("Data %s: excepted %t, got %t", file.Name(), false, true)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+const (
+	LinkHard    = "hard"
+	LinkSym     = "sym"
+	LinkRelSym  = "relsym"
+	LinkReflink = "reflink"
+	LinkCopy    = "copy"
+	LinkAuto    = "auto"
+)
+
+var (
+	linkModeFlag     = flag.String("link-mode", LinkHard, "how files are linked: hard, sym, relsym, reflink, copy or auto")
+	linkFallbackFlag = flag.String("link-fallback", LinkCopy, "link mode used by auto when hard links and reflinks fail: sym, relsym or copy")
+
+	//cleared when src and dst are known to be on different devices, auto does not try hard links then
+	linkSameDevice = true
+)
+
+// checkLinkFlags exits if the link mode, fallback or collision flags are wrong.
+func checkLinkFlags() {
+	switch *linkModeFlag {
+	case LinkHard, LinkSym, LinkRelSym, LinkReflink, LinkCopy, LinkAuto:
+	default:
+		fmt.Println("link-mode must be hard, sym, relsym, reflink, copy or auto")
+		os.Exit(1)
+	}
+
+	switch *linkFallbackFlag {
+	case LinkSym, LinkRelSym, LinkCopy:
+	default:
+		fmt.Println("link-fallback must be sym, relsym or copy")
+		os.Exit(1)
+	}
+
+	switch *onCollisionFlag {
+	case CollisionSkip, CollisionSuffix, CollisionOverwrite, CollisionFail:
+	default:
+		fmt.Println("on-collision must be skip, suffix, overwrite or fail")
+		os.Exit(1)
+	}
+
+	if *badCRCFlag != BadCRCRefuse && *badCRCFlag != BadCRCWarn {
+		fmt.Println("bad-crc must be refuse or warn")
+		os.Exit(1)
+	}
+	if *crcWorkersFlag < 1 {
+		fmt.Println("crc-workers must be at least 1")
+		os.Exit(1)
+	}
+}
+
+// getDevice returns the device of file, or of its nearest existing parent.
+func getDevice(file string) (uint64, bool) {
+	for file != "" {
+		if info, err := os.Stat(file); err == nil {
+			dev, _, ok := getFileID(info)
+			return dev, ok
+		}
+
+		parent := filepath.Dir(file)
+		if parent == file {
+			break
+		}
+		file = parent
+	}
+
+	return 0, false
+}
+
+// checkDevices tells up front if hard links from src to dst are possible.
+// It exits when they are needed but impossible.
+func checkDevices(src, dst string) {
+	srcDev, ok1 := getDevice(src)
+	dstDev, ok2 := getDevice(dst)
+	if !ok1 || !ok2 || srcDev == dstDev {
+		return
+	}
+
+	switch *linkModeFlag {
+	case LinkHard:
+		fmt.Printf("src and dst are on different devices, hard links are impossible. Use -link-mode.\n")
+		os.Exit(1)
+	case LinkAuto:
+		fmt.Printf("src and dst are on different devices, trying reflinks, then %s.\n", *linkFallbackFlag)
+		linkSameDevice = false
+	}
+}
+
+// createLink links or copies oldPath to newPath with the link mode.
+// It returns the mode that was used, auto ending up as one of the others.
+func createLink(oldPath, newPath string) (string, error) {
+	mode := *linkModeFlag
+	if mode != LinkAuto {
+		return mode, createLinkMode(mode, oldPath, newPath)
+	}
+
+	if linkSameDevice {
+		err := os.Link(oldPath, newPath)
+		if err == nil || !errors.Is(err, syscall.EXDEV) {
+			return LinkHard, err
+		}
+	}
+
+	//btrfs and xfs share the data of both files until one is changed
+	if err := reflinkFile(oldPath, newPath); err == nil {
+		return LinkReflink, nil
+	}
+
+	return *linkFallbackFlag, createLinkMode(*linkFallbackFlag, oldPath, newPath)
+}
+
+func createLinkMode(mode, oldPath, newPath string) error {
+	switch mode {
+	case LinkHard:
+		return os.Link(oldPath, newPath)
+	case LinkSym:
+		target, err := filepath.Abs(oldPath)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(target, newPath)
+	case LinkRelSym:
+		target, err := getRelativeTarget(oldPath, newPath)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(target, newPath)
+	case LinkReflink:
+		return reflinkFile(oldPath, newPath)
+	case LinkCopy:
+		return copyFile(oldPath, newPath)
+	}
+
+	return fmt.Errorf("unknown link mode %s", mode)
+}
+
+// getRelativeTarget returns the path of oldPath relative to the directory of newPath.
+func getRelativeTarget(oldPath, newPath string) (string, error) {
+	oldAbs, err := filepath.Abs(oldPath)
+	if err != nil {
+		return "", err
+	}
+
+	newAbs, err := filepath.Abs(newPath)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Rel(filepath.Dir(newAbs), oldAbs)
+}
+
+// copyFile copies oldPath to newPath, keeping the permissions and modification time.
+// A partial copy is removed.
+func copyFile(oldPath, newPath string) error {
+	in, err := os.Open(oldPath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(newPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, in)
+	if err2 := out.Close(); err == nil {
+		err = err2
+	}
+	if err != nil {
+		os.Remove(newPath)
+		return err
+	}
+
+	return os.Chtimes(newPath, info.ModTime(), info.ModTime())
+}
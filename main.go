@@ -7,10 +7,11 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
-	"regexp"
 	"sort"
-	"strconv"
 	"strings"
+
+	"github.com/xsm1997/animeLinker/media"
+	"github.com/xsm1997/animeLinker/release"
 )
 
 const (
@@ -25,7 +26,10 @@ var (
 	destinationDir = flag.String("dst", "", "destination dir")
 	ruleFlag       = flag.String("rule", "", "episode naming rule")
 	mode           = flag.String("mode", "anime", "mode: anime or movie")
+	answersFile    = flag.String("answers", "", "yaml/json file with per-directory answers")
+	planFile       = flag.String("o", "plan.json", "plan file written by the plan command")
 	rule           = DefaultRuleAnime
+	batchMode      bool
 
 	videoSuffix = []string{
 		".mkv",
@@ -40,22 +44,8 @@ var (
 		".mka",
 	}
 
-	deleteRegex = []string{
-		`\[.*?\]`, //find strings between []
-		`\(.*?\)`, //find strings between ()
-		`【.*?】`,   //find strings between 【】
-		`（.*?）`,   //find strings between （）
-		`<.*?>`,   //find strings between <>
-		`1080[pP]`,
-		`2160[pP]`,
-		`4[kK]`,
-		`[Bb]lu[Rr]ay`,
-		`BLURAY`,
-	}
-
-	deleteChar = []string{
-		" - ",
-	}
+	//parser of names, its tables are set from the config
+	parser = release.NewParser()
 
 	scanner *bufio.Scanner
 )
@@ -84,186 +74,33 @@ func getSplitPath(path string) (string, string) {
 	return path[:index], path[index+1:]
 }
 
-func deletePatterns(name string) string {
-	for _, str := range deleteRegex {
-		regex := regexp.MustCompile(str)
-		name = regex.ReplaceAllString(name, "")
-	}
-
-	if *mode == "movie" {
-		name = strings.ReplaceAll(name, ".", " ")
-	}
-
-	return name
-}
-
 func probeVideoName(name string) string {
-	name, ext := getExtName(name)
-	origName := name
-
-	//delete patterns
-	name = deletePatterns(name)
-	name = strings.TrimSpace(name)
-
-	if name == "" {
-		name = origName
-
-		regex := regexp.MustCompile(`\[.+?\]`)
-		fields := regex.FindAllString(name, -1)
-
-		newFields := make([]string, 0)
-		for _, str := range fields {
-			str = str[1 : len(str)-1]
-			str = strings.TrimSpace(str)
-			if str != "" {
-				newFields = append(newFields, str)
-			}
-		}
-
-		if len(newFields) == 0 {
-			return ""
-		} else if len(newFields) == 1 {
-			name = newFields[0]
-		} else {
-			name = newFields[1]
-		}
-	}
-
-	//delete chars
-	for _, char := range deleteChar {
-		name = strings.ReplaceAll(name, char, " ")
-	}
-
-	//delete EP number
-	regex := regexp.MustCompile(`((\[?(CM|OVA|#)?\d{1,3}(v\d{1,2}|\.\d{1,2})?\]?)|(\[?第\d{1,3}(v\d{1,2}|\.\d{1,2})?[话話]\]?))`)
-	name = regex.ReplaceAllString(name, "")
-	name = strings.TrimSpace(name)
-
-	if *mode == "movie" {
-		ssIndex := strings.Index(name, "  ")
-		if ssIndex > 0 {
-			name = name[:ssIndex]
-		}
-	}
-
-	return name + ext
+	return parser.ProbeName(name)
 }
 
 func getEpisode(name string) string {
-	name, _ = getExtName(name)
-
-	exxRegex := regexp.MustCompile(`[Ee][Pp]?\d{1,3}`)
-	exxStr := exxRegex.FindString(name)
-	if exxStr != "" {
-		exxStr = regexp.MustCompile("([EePp ]|-)").ReplaceAllString(exxStr, "")
-		return exxStr
-	}
-
-	numbers := ""
-
-	//detect [01], [OVA1], 第01話, [第01話], etc.
-	regex := regexp.MustCompile(`\[((第\d{1,3}(v\d{1,2}|\.\d{1,2})?[话話])|((CM|OVA|#)?\d{1,3}(v\d{1,2}|\.\d{1,2})?))\]`)
-	numbersSlice := regex.FindAllString(name, -1)
-	if len(numbersSlice) > 0 {
-		numbers = numbersSlice[len(numbersSlice)-1]
-		numbers = regexp.MustCompile(`[\[\]第话話#]`).ReplaceAllString(numbers, "")
-	}
-
-	name = deletePatterns(name)
-	name = strings.TrimSpace(name)
-
-	if numbers == "" {
-		//detect - 01, -12.5, etc.
-		regex = regexp.MustCompile(`\s*-\s*((第\d{1,3}(v\d{1,2}|\.\d{1,2})?[话話])|((CM|OVA|#)?\d{1,3}(v\d{1,2}|\.\d{1,2})?))`)
-		numbersSlice = regex.FindAllString(name, -1)
-		if len(numbersSlice) > 0 {
-			numbers = numbersSlice[len(numbersSlice)-1]
-			numbers = regexp.MustCompile(`[-第话話#]`).ReplaceAllString(numbers, "")
-			numbers = strings.TrimSpace(numbers)
-		}
-	}
-
-	if numbers == "" {
-		regex = regexp.MustCompile(`\s+((第\d{1,3}(v\d{1,2}|\.\d{1,2})?[话話])|((CM|OVA|#)?\d{1,3}(v\d{1,2}|\.\d{1,2})?))`)
-		numbersSlice = regex.FindAllString(name, -1)
-		if len(numbersSlice) > 0 {
-			numbers = numbersSlice[len(numbersSlice)-1]
-			numbers = regexp.MustCompile(`[第话話#]`).ReplaceAllString(numbers, "")
-			numbers = strings.TrimSpace(numbers)
-		}
-	}
-
-	return numbers
+	return parser.ProbeEpisode(name)
 }
 
-func getSeason(name string) string {
-	name, _ = getExtName(name)
-
-	sxxRegex := regexp.MustCompile(`[Ss]\d{1,3}}`)
-	sxxStr := sxxRegex.FindString(name)
-	if sxxStr != "" {
-		return sxxStr
-	}
-
-	return "S01"
+// probeTitle works like probeVideoName, but takes the season token out of the title,
+// so all seasons of a show end up with the same name.
+func probeTitle(name string) string {
+	return parser.ProbeTitle(name)
 }
 
-func checkExtName(ext string) bool {
-	if len(ext) > 6 { //long ext names
-		return false
-	}
-
-	ext = ext[1:]
-	for _, c := range ext {
-		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
-			return false
-		}
+// getSeason returns the season directory of a video.
+// The names are searched in order, usually the file name, the release directory and the parent directory.
+func getSeason(names ...string) string {
+	season := parser.Season(names...)
+	if season == 0 {
+		season = 1
 	}
 
-	return true
+	return fmt.Sprintf("S%02d", season)
 }
 
 func getExtName(name string) (string, string) {
-	extname := path.Ext(name)
-
-	if extname == "" {
-		return name, ""
-	}
-
-	if !checkExtName(extname) {
-		return name, ""
-	}
-	name = name[:len(name)-len(extname)]
-
-	//handle "[02].sc.ass" etc.
-	extname2 := path.Ext(name)
-	if extname2 == "" {
-		return name, extname
-	}
-
-	if !checkExtName(extname2) {
-		return name, extname
-	}
-
-	name2 := name[:len(name)-len(extname2)]
-
-	matchList := []string{
-		".sc", ".tc", ".chs", ".cht", ".en", ".jp",
-	}
-
-	f := false
-	for _, match := range matchList {
-		if extname2 == match {
-			f = true
-			break
-		}
-	}
-
-	if f {
-		return name2, extname2 + extname
-	} else {
-		return name, extname
-	}
+	return parser.SplitExt(name)
 }
 
 func getDirName(name string) string {
@@ -313,15 +150,34 @@ func getVideosInDir(dir string) []string {
 }
 
 func checkFileExists(file string) bool {
-	if _, err := os.Stat(file); os.IsNotExist(err) {
-		return false
-	} else if err == nil {
-		return true
-	} else {
+	exists, err := statFileExists(file)
+	if err != nil {
 		fmt.Printf("os.Stat unknown error:%s.\n", err.Error())
 		os.Exit(1)
-		return false
 	}
+
+	return exists
+}
+
+// statFileExists tells if file exists, returning the errors other than a missing file
+// instead of exiting, for the links of a directory to fail alone.
+func statFileExists(file string) (bool, error) {
+	_, err := os.Stat(file)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// statDirEmpty tells if dir is empty or missing, returning the errors.
+func statDirEmpty(dir string) (bool, error) {
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return true, nil
+	}
+
+	return len(files) == 0, err
 }
 
 func checkDirEmpty(dir string) bool {
@@ -366,7 +222,10 @@ func getVideosCount(videos []string) int {
 	return count
 }
 
-func generatesVideoNames(videos, episodes []string, manual bool) (newFilenames []string) {
+// generatesVideoNames names the links of videos by the rule.
+// names are the source file names and releaseName the source directory name, used for release tags.
+// infos are the headers of the source files, for the tags missing from the names.
+func generatesVideoNames(videos, episodes, names []string, infos []*media.Info, releaseName string, manual bool) (newFilenames []string) {
 	newFilenames = make([]string, len(videos))
 
 	for i, video := range videos {
@@ -375,17 +234,23 @@ func generatesVideoNames(videos, episodes []string, manual bool) (newFilenames [
 			continue
 		}
 
-		newName := rule
+		fields := getNameFields(video, episodes[i], names[i], releaseName)
+		addMediaFields(fields, infos[i])
 
 		var extName string
 		video, extName = getExtName(video)
 		video = strings.TrimSpace(video)
-		episode := episodes[i]
 
-		newName = strings.ReplaceAll(newName, NameReplaceStr, video)
-		newName = strings.ReplaceAll(newName, EpisodeReplaceStr, episode)
-		newName = strings.TrimSpace(newName)
-		newName += extName
+		//extras have no season or episode, the rule would not fit them
+		fileRule := rule
+		if parser.IsExtraDir(fields["dir"]) {
+			fileRule = DefaultRuleAnime
+		}
+
+		newName := renderTemplate(fileRule, fields)
+		if !strings.Contains(fileRule, "{ext}") {
+			newName += extName
+		}
 
 		newName = strings.TrimSpace(newName)
 
@@ -426,7 +291,7 @@ func manualLink(videos, episodes, names []string, origLinkDir string) (newVideos
 	newVideos = make([]string, len(videos))
 	newEpisodes = make([]string, len(episodes))
 
-	season := "S01"
+	season := getProbedSeason(videos)
 	seasonPrompt := true
 
 	if *mode == "anime" {
@@ -445,25 +310,33 @@ func manualLink(videos, episodes, names []string, origLinkDir string) (newVideos
 				episode = input
 			}
 
+			//specials and extras keep their own directory unless told otherwise
+			fileSeason := season
+			if probed, _ := getSplitPath(videos[i]); isSpecialDir(probed) {
+				fileSeason = probed
+			}
+
 			if episode != "$" {
 				if seasonPrompt {
-					fmt.Printf("Input season name of '%s' (# for empty, ! for all %s): [%s] ", name, season, season)
+					fmt.Printf("Input season name of '%s' (# for empty, ! for all %s): [%s] ", name, season, fileSeason)
 					input2 := getLine()
 
 					if input2 == "!" {
 						seasonPrompt = false
 					} else if input2 == "#" {
 						season = ""
+						fileSeason = ""
 					} else if input2 != "" {
 						season = input2
+						fileSeason = input2
 					}
 				}
 
 			}
 
 			newVideos[i] = videoName + ext
-			if season != "" {
-				newVideos[i] = path.Join(season, newVideos[i])
+			if fileSeason != "" {
+				newVideos[i] = path.Join(fileSeason, newVideos[i])
 			}
 
 			if episode == "$" {
@@ -527,7 +400,28 @@ func manualLink(videos, episodes, names []string, origLinkDir string) (newVideos
 	return
 }
 
-func probeDirInner(dir, destDir string, videos []string, level int, origDestDir string) {
+// checkEpisodesUnique tells if the episodes probed for the regular files are all found and different.
+// The subtitles of an episode share its number, so episodes are compared per extension.
+func checkEpisodesUnique(episodes, names []string, regular []bool) bool {
+	found := make(map[string]bool)
+	for i, episode := range episodes {
+		if !regular[i] {
+			continue
+		}
+
+		_, ext := getExtName(names[i])
+		if episode == "" || found[episode+ext] {
+			return false
+		}
+		found[episode+ext] = true
+	}
+
+	return true
+}
+
+// probeDirInner probes and links videos in dir.
+// releasePath is dir itself, or the video file for a release of just one file.
+func probeDirInner(dir, destDir string, videos []string, level int, origDestDir, releasePath string) {
 	var prompt string
 
 	if videos == nil {
@@ -540,37 +434,86 @@ func probeDirInner(dir, destDir string, videos []string, level int, origDestDir
 		return
 	}
 
-	_, dirName := getSplitPath(dir)
-	animeName := probeVideoName(dirName)
+	_, dirName := getSplitPath(releasePath)
+	if releasePath != dir {
+		dirName, _ = getExtName(dirName)
+	}
+	animeName := probeTitle(dirName)
+	parentDir, _ := getSplitPath(releasePath)
+	_, parentName := getSplitPath(parentDir)
 	animeName = strings.TrimSpace(animeName)
+
+	confidence := newDirConfidence()
+	confidence.lower(parser.Parse(dirName).TitleConfidence, "title of "+dirName)
+
+	//the number changing between the files is the episode
+	diffEpisodes, diffTitle := parser.DiffEpisodes(videos)
+	if animeName == "" {
+		animeName = diffTitle
+		confidence.lower(release.ConfidenceMedium, "title from the file names")
+	}
 	if animeName == "" {
 		animeName = "Unknown"
+		confidence.lower(release.ConfidenceNone, "no title")
+	}
+
+	answer := findAnswer(releasePath)
+	if answer != nil && answer.Skip {
+		fmt.Printf("Skipping %s by answers file.\n", releasePath)
+		return
 	}
 
 	//check directory empty.
 	//if not empty, ask user if he wants to create a subdirectory.
 	//useful in linking just one movie folder.
-	if !checkDirEmpty(destDir) && level == 0 {
+	//a directory that cannot be read fails when linking.
+	if empty, err := statDirEmpty(destDir); err == nil && !empty && level == 0 {
 		fmt.Println()
 		fmt.Printf("Directory %s not empty. Do you need create a sub-directory in it? [Y/n] ", destDir)
-		prompt = getLine()
+		if batchMode {
+			prompt = ""
+			fmt.Println()
+		} else {
+			prompt = getLine()
+		}
 
 		if prompt != "n" && prompt != "N" {
-			destDir = path.Join(destDir, animeName)
-			origDestDir = path.Join(origDestDir, animeName)
+			showDir := getShowDirName(dirName)
+			destDir = path.Join(destDir, showDir)
+			origDestDir = path.Join(origDestDir, showDir)
 		}
 	}
 
 	newVideos := make([]string, len(videos))
 	episodes := make([]string, len(videos))
+	regular := make([]bool, len(videos))
+	episodeConfidence := make([]float64, len(videos))
 
 	for i, videoName := range videos {
-		_, ext := getExtName(videoName)
-		newName := animeName + ext
-		episode := getEpisode(videoName)
+		parsed := parser.Parse(videoName)
+		newName := animeName + getLanguageExt(dir, videoName)
+		episode := parsed.Episode
+		regular[i] = parsed.SpecialDir == ""
+		episodeConfidence[i] = parsed.EpisodeConfidence
 
 		if *mode == "anime" {
-			season := getSeason(videoName)
+			seasonNumber := parsed.Season
+			if seasonNumber == 0 {
+				seasonNumber = parser.Season(dirName, parentName)
+			}
+			if seasonNumber == 0 {
+				seasonNumber = 1
+			}
+			season := fmt.Sprintf("S%02d", seasonNumber)
+			confidence.lower(parsed.SeasonConfidence, "season of "+videoName)
+
+			if parsed.SpecialDir != "" {
+				season = parsed.SpecialDir
+				//extras are named by their token, like NCOP1
+				if parser.IsExtraDir(season) {
+					episode = parsed.Special
+				}
+			}
 			newName = path.Join(season, newName)
 		}
 
@@ -578,18 +521,65 @@ func probeDirInner(dir, destDir string, videos []string, level int, origDestDir
 		episodes[i] = episode
 	}
 
+	infos := probeVideos(dir, videos)
+	if *mode == "anime" {
+		classifyByDuration(videos, newVideos, episodes, regular, infos, confidence)
+	}
+
+	if diffEpisodes != nil && !checkEpisodesUnique(episodes, newVideos, regular) {
+		for i := range episodes {
+			if regular[i] && diffEpisodes[i] != "" {
+				episodes[i] = diffEpisodes[i]
+				episodeConfidence[i] = release.ConfidenceMedium
+			}
+		}
+	}
+
+	if *mode == "anime" {
+		for i, videoName := range videos {
+			if regular[i] {
+				confidence.lower(episodeConfidence[i], "episode of "+videoName)
+			}
+		}
+
+		if !checkEpisodesUnique(episodes, newVideos, regular) {
+			confidence.lower(release.ConfidenceLow, "duplicate episode numbers")
+		}
+	}
+
+	//a single video file has no subfolders of its own
+	var companionOf []int
+	videos, newVideos, episodes, companionOf = addCompanions(dir, videos, newVideos, episodes, releasePath == dir)
+	infos = append(infos, make([]*media.Info, len(videos)-len(infos))...)
+
 	linkWithNewNames := true
 
 	var newFilenames []string
 
 	flagManualLink := false
 
+	if answer != nil {
+		var linkDir string
+		_, linkDir = getSplitPath(destDir)
+		newVideos, episodes, linkDir = answerLink(answer, newVideos, episodes, videos, linkDir)
+
+		oldDir := getDirName(origDestDir)
+		destDir = path.Join(oldDir, linkDir)
+
+		flagManualLink = true
+		confidence = newDirConfidence()
+	}
+
+	autoAccept := confidence.sure()
+
 	for {
-		if checkFileExists(destDir) {
+		//errors are reported when linking
+		if exists, _ := statFileExists(destDir); exists {
 			fmt.Printf("[WARNING] Directory '%s' already exists!\n", destDir)
 		}
 
-		newFilenames = generatesVideoNames(newVideos, episodes, flagManualLink)
+		newFilenames = generatesVideoNames(newVideos, episodes, videos, infos, dirName, flagManualLink)
+		followCompanions(newFilenames, newVideos, episodes, companionOf)
 
 		fmt.Println()
 
@@ -601,8 +591,16 @@ func probeDirInner(dir, destDir string, videos []string, level int, origDestDir
 			fmt.Printf("[VIDEO] %s => %s\n", oldName, newName)
 		}
 
+		if confidence.score < release.ConfidenceHigh {
+			fmt.Printf("[CONFIDENCE] %.1f, lowest for %s\n", confidence.score, confidence.reason)
+		}
+
 		fmt.Println()
 
+		if batchMode || autoAccept {
+			break
+		}
+
 		prompt = ""
 		for prompt != "y" && prompt != "n" && prompt != "Y" && prompt != "N" {
 			fmt.Printf("Is that right? [Y/n] ")
@@ -647,90 +645,26 @@ func probeDirInner(dir, destDir string, videos []string, level int, origDestDir
 				linkWithNewNames = true
 				flagManualLink = true
 			}
+			autoAccept = false
 		}
 	}
 
-	fmt.Println("Now linking the files...")
-
 	if !linkWithNewNames {
 		_, dirName := getSplitPath(dir)
 		dirBase, _ := getSplitPath(destDir)
 		destDir = path.Join(dirBase, dirName)
 	}
 
-	if _, err := os.Stat(destDir); os.IsNotExist(err) {
-		fmt.Printf("dest not exists, creating.\n")
-		err2 := os.MkdirAll(destDir, 0777)
-		if err2 != nil {
-			fmt.Printf("os.MkdirAll error: %s.\n", err2.Error())
-			os.Exit(1)
-			return
-		}
-	}
+	entries := buildLinkEntries(dir, destDir, videos, newVideos, newFilenames, episodes, companionOf, linkWithNewNames)
 
-	for i, newName := range newFilenames {
-		if episodes[i] == "" && *mode == "anime" || episodes[i] == "$$$$$" {
-			//omitted video
-			continue
-		}
-
-		oldName := videos[i]
-
-		oldPath := path.Join(dir, oldName)
-		newPath := ""
-		if linkWithNewNames {
-			newPath = path.Join(destDir, newName)
-		} else {
-			newPath = path.Join(destDir, oldName)
-		}
-
-		newPathDir, _ := getSplitPath(newPath)
-		if _, err := os.Stat(newPathDir); os.IsNotExist(err) {
-			err2 := os.MkdirAll(newPathDir, 0777)
-			if err2 != nil {
-				fmt.Printf("os.MkdirAll error: %s.\n", err2.Error())
-				os.Exit(1)
-				return
-			}
-		}
+	if planMode {
+		plannedLinks = append(plannedLinks, entries...)
+		return
+	}
 
-		if _, err := os.Stat(newPath); os.IsNotExist(err) {
-			err2 := os.Link(oldPath, newPath)
-			if err2 != nil {
-				fmt.Printf("Link error: %s.\n", err2.Error())
-				os.Exit(1)
-				return
-			}
-		} else if err == nil {
-			for i := 2; i <= 99; i++ {
-				var newName2, extName string
-				if linkWithNewNames {
-					newName2, extName = getExtName(newName)
-				} else {
-					newName2, extName = getExtName(oldName)
-				}
+	fmt.Println("Now linking the files...")
 
-				newName2 += " (" + strconv.Itoa(i) + ")"
-				newName2 += extName
-				newPath2 := path.Join(destDir, newName2)
-
-				if _, err2 := os.Stat(newPath2); os.IsNotExist(err2) {
-					err3 := os.Link(oldPath, newPath2)
-					if err3 != nil {
-						fmt.Printf("Link error: %s.\n", err3.Error())
-						os.Exit(1)
-						return
-					} else {
-						break
-					}
-				}
-			}
-		} else {
-			fmt.Printf("os.Stat unknown error:%s.\n", err.Error())
-			os.Exit(1)
-			return
-		}
-	}
+	linkDirEntries(dir, entries)
 }
 
 func probeDir(dir, destDir string) {
@@ -738,7 +672,7 @@ func probeDir(dir, destDir string) {
 
 	//check video files exists
 	if len(videos) > 0 {
-		probeDirInner(dir, destDir, videos, 0, destDir)
+		probeDirInner(dir, destDir, videos, 0, destDir, dir)
 	} else {
 		//search for subdirectories
 		files, err := ioutil.ReadDir(dir)
@@ -757,30 +691,173 @@ func probeDir(dir, destDir string) {
 			if file.IsDir() {
 				dirName := file.Name()
 
-				fmt.Printf("Search into %s? [y/N] ", dirName)
+				srcDir := path.Join(dir, dirName)
+
 				var prompt string
-				prompt = getLine()
+				if batchMode {
+					//every release is searched in batch mode, the answers file may skip some.
+					prompt = "y"
+				} else {
+					fmt.Printf("Search into %s? [y/N] ", dirName)
+					prompt = getLine()
+				}
 
 				if prompt == "y" || prompt == "Y" {
-					destDir2 := probeVideoName(dirName)
-					destDir2 = strings.TrimSpace(destDir2)
-					if destDir2 == "" {
-						destDir2 = "Unknown"
-					}
-					destDir2 = path.Join(destDir, destDir2)
+					destDir2 := path.Join(destDir, getShowDirName(dirName))
 					origDestDir := path.Join(destDir, dirName)
 
-					srcDir := path.Join(dir, dirName)
-
-					probeDirInner(srcDir, destDir2, nil, 1, origDestDir)
+					probeDirInner(srcDir, destDir2, nil, 1, origDestDir, srcDir)
 				}
 			}
 		}
 	}
 }
 
+// probeRelease probes one release, which is a directory or a single video file,
+// and links it into its own sub-directory of destDir.
+// A directory of releases is handed to probeDir.
+func probeRelease(release, destDir string) {
+	info, err := os.Stat(release)
+	if err != nil {
+		fmt.Printf("Cannot stat %s. error: %s.\n", release, err.Error())
+		return
+	}
+
+	dir, name := getSplitPath(release)
+	videos := []string{name}
+	releaseName := name
+
+	if info.IsDir() {
+		videos = getVideosInDir(release)
+		if len(videos) == 0 {
+			probeDir(release, destDir)
+			return
+		}
+		dir = release
+	} else {
+		if getVideosCount(videos) == 0 {
+			fmt.Printf("%s is not a video, ignored.\n", release)
+			return
+		}
+		releaseName, _ = getExtName(name)
+	}
+
+	destDir2 := path.Join(destDir, getShowDirName(releaseName))
+	probeDirInner(dir, destDir2, videos, 1, path.Join(destDir, releaseName), release)
+}
+
 func main() {
-	flag.Parse()
+	flag.BoolVar(&batchMode, "yes", false, "accept the probed names without prompting")
+	flag.BoolVar(&batchMode, "batch", false, "same as -yes")
+
+	//the first non-flag argument selects the command, linking is the default.
+	command := "link"
+	args := os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command = args[0]
+		args = args[1:]
+	}
+	flag.CommandLine.Parse(args)
+
+	err := loadConfig()
+	if err == nil {
+		err = applyConfig()
+	}
+	if err != nil {
+		fmt.Printf("Cannot load config: %s.\n", err.Error())
+		os.Exit(1)
+	}
+
+	switch command {
+	case "link":
+	case "plan":
+		planMode = true
+	case "watch":
+		//nobody is there to answer prompts
+		batchMode = true
+	case "hook":
+		batchMode = true
+		if !setupHook() {
+			return
+		}
+	case "apply":
+		checkLinkFlags()
+
+		if flag.NArg() != 1 {
+			fmt.Println("usage: animelinker apply plan.json")
+			os.Exit(1)
+		}
+
+		entries, err := readPlan(flag.Arg(0))
+		if err != nil {
+			fmt.Printf("Cannot read plan: %s.\n", err.Error())
+			os.Exit(1)
+		}
+
+		journalDir = *destinationDir
+		if journalDir == "" {
+			journalDir = getCommonDir(entries)
+		}
+
+		dirs, groups := groupEntries(entries)
+		for i, dir := range dirs {
+			linkDirEntries(dir, groups[i])
+		}
+		reportFailures()
+		return
+	case "undo":
+		if *destinationDir == "" {
+			fmt.Println("dst must not be empty")
+			os.Exit(1)
+		}
+
+		journalDir = *destinationDir
+		if err := undoRun(*destinationDir, flag.Arg(0)); err != nil {
+			fmt.Printf("Cannot undo: %s.\n", err.Error())
+			os.Exit(1)
+		}
+		return
+	case "parse":
+		if flag.NArg() == 0 {
+			fmt.Println(`usage: animelinker parse [-json] "<filename>"...`)
+			os.Exit(1)
+		}
+
+		parser.Movie = *mode == "movie"
+		parseNames(flag.Args())
+		return
+	case "gc":
+		if *destinationDir == "" {
+			fmt.Println("dst must not be empty")
+			os.Exit(1)
+		}
+
+		journalDir = *destinationDir
+		if err := gcDir(*destinationDir); err != nil {
+			fmt.Printf("Cannot collect orphaned files: %s.\n", err.Error())
+			os.Exit(1)
+		}
+		return
+	case "verify":
+		if *destinationDir == "" {
+			fmt.Println("dst must not be empty")
+			os.Exit(1)
+		}
+
+		journalDir = *destinationDir
+		problems, err := verifyDir(*destinationDir)
+		if err != nil {
+			fmt.Printf("Cannot verify: %s.\n", err.Error())
+			os.Exit(1)
+		}
+		if problems > 0 {
+			os.Exit(1)
+		}
+		return
+	default:
+		fmt.Printf("unknown command %s, must be link, plan, apply, undo, watch, hook, parse, gc or verify\n", command)
+		os.Exit(1)
+	}
 
 	if *sourceDir == "" {
 		fmt.Println("src must not be empty")
@@ -797,15 +874,54 @@ func main() {
 		os.Exit(1)
 	}
 
+	checkLinkFlags()
+	checkDevices(*sourceDir, *destinationDir)
+
 	if *ruleFlag == "" {
-		if *mode == "movie" {
-			rule = DefaultRuleMovie
-		}
+		rule = getDefaultRule()
 	} else {
 		rule = *ruleFlag
 	}
 
+	if *dirRuleFlag != "" {
+		dirRule = *dirRuleFlag
+	}
+
+	parser.Movie = *mode == "movie"
+
+	if *answersFile != "" {
+		if err := loadAnswers(*answersFile); err != nil {
+			fmt.Printf("Cannot load answers file: %s.\n", err.Error())
+			os.Exit(1)
+		}
+	}
+
+	if !planMode {
+		journalDir = *destinationDir
+	}
+
 	scanner = bufio.NewScanner(os.Stdin)
 
+	if command == "watch" {
+		watchDir(*sourceDir, *destinationDir)
+		return
+	}
+
+	if command == "hook" {
+		probeRelease(*sourceDir, *destinationDir)
+		reportFailures()
+		return
+	}
+
 	probeDir(*sourceDir, *destinationDir)
+	reportFailures()
+
+	if planMode {
+		if err := writePlan(*planFile, plannedLinks); err != nil {
+			fmt.Printf("Cannot write plan: %s.\n", err.Error())
+			os.Exit(1)
+		}
+
+		fmt.Printf("Plan of %d files written to %s.\n", len(plannedLinks), *planFile)
+	}
 }
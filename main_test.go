@@ -75,6 +75,8 @@ func TestGetEpisode(t *testing.T) {
 		`世界最高の暗殺者、異世界貴族に転生する メニュー動画 vol1 (BD 1920x1080 x265 ALAC).mp4`,
 		`[ANK-Raws] 血界戦線 CM01 (BDrip 1920x1080 HEVC-YUV420P10 FLAC).mkv`,
 		`[AI-Raws][アニメ BD] 牙狼-GARO- -炎の刻印- ゆるがろ #01 (H264 10bit 1920x1080 FLAC)[1B793118].mkv`,
+		`[Grp] Show - 03 [1080p][ABCDE123].mkv`,
+		`[Grp] Show - 05 [1080p][7E12ABCD].mkv`,
 	}
 
 	out1 := []string{
@@ -99,6 +101,8 @@ func TestGetEpisode(t *testing.T) {
 		``,
 		`CM01`,
 		`01`,
+		`03`,
+		`05`,
 	}
 
 	for i, data := range in {
@@ -113,3 +117,126 @@ func TestGetEpisode(t *testing.T) {
 		}
 	}
 }
+
+func TestGetSeason(t *testing.T) {
+	in := []string{
+		`[DanMachi S3][02][BDRIP][1080P][H264_FLAC].mkv`,
+		`[Grp] Show S02E03 [1080p].mkv`,
+		`[Grp] Show Season 2 - 03.mkv`,
+		`[Grp] Show 2nd Season - 03.mkv`,
+		`[Grp] 进击的巨人 第三季 [01].mp4`,
+		`[Grp] 某科学的超电磁炮 第2期 [01].mp4`,
+		`[2020][Dungeon ni Deai o Motomeru no wa Machigatte Iru Darouka III][BDRIP][1080P][1-12Fin+SP]`,
+		`[Snow-Raws] BANANA FISH [01].mkv`,
+		`[EMD]Arslan Senki[GB_BIG5][X264_AAC][1280X720][7BAA2B61]`,
+	}
+
+	out1 := []string{
+		`S03`,
+		`S02`,
+		`S02`,
+		`S02`,
+		`S03`,
+		`S02`,
+		`S03`,
+		`S01`,
+		`S01`,
+	}
+
+	for i, data := range in {
+		o1 := out1[i]
+
+		r1 := getSeason(data)
+
+		if r1 != o1 {
+			t.Errorf("Data %s: excepted %s, got %s", data, o1, r1)
+		}
+	}
+}
+
+func TestProbeTitle(t *testing.T) {
+	in := []string{
+		`[2020][Dungeon ni Deai o Motomeru no wa Machigatte Iru Darouka III][BDRIP][1080P][1-12Fin+SP]`,
+		`[Grp] Show Season 2 [BDRip]`,
+		`[Grp] Show 2nd Season [BDRip]`,
+		`[Grp] 进击的巨人 第三季 [BDRip]`,
+		`[Snow-Raws] BANANA FISH`,
+	}
+
+	out1 := []string{
+		`Dungeon ni Deai o Motomeru no wa Machigatte Iru Darouka`,
+		`Show`,
+		`Show`,
+		`进击的巨人`,
+		`BANANA FISH`,
+	}
+
+	for i, data := range in {
+		o1 := out1[i]
+
+		r1 := probeTitle(data)
+
+		if r1 != o1 {
+			t.Errorf("Data %s: excepted %s, got %s", data, o1, r1)
+		}
+	}
+}
+
+func TestRenderTemplate(t *testing.T) {
+	fields := getNameFields(`S01/Show.sc.ass`, `3v2`, `[Grp] Show - 03v2 [WEB-DL 1080p x264][ABCD1234].sc.ass`, `[Grp] Show (2020) [WEB-DL]`)
+
+	in := []string{
+		`$name - $episode`,
+		`{title}< ({year})>/Season {season:2}/{title} S{season:2}E{episode:2}`,
+		`{title} - {episode:3}< [{group}]>< [{crc}]>< v{version}>`,
+		`{title}< [{source} {resolution} {codec}]><.{lang}>{ext}`,
+		`{title}< [{missing}]>`,
+	}
+
+	out1 := []string{
+		`S01/Show - 3`,
+		`Show (2020)/Season 01/Show S01E03`,
+		`Show - 003 [Grp] [ABCD1234] v2`,
+		`Show [WEB-DL 1080p AVC].sc.ass`,
+		`Show`,
+	}
+
+	for i, data := range in {
+		o1 := out1[i]
+
+		r1 := renderTemplate(data, fields)
+
+		if r1 != o1 {
+			t.Errorf("Data %s: excepted %s, got %s", data, o1, r1)
+		}
+	}
+}
+
+func TestMatchCompanion(t *testing.T) {
+	videos := []string{
+		`[VCB-Studio] Show A [01][1080p].mkv`,
+		`[VCB-Studio] Show A [02][1080p].mkv`,
+		`[VCB-Studio] Other B [01][1080p].mkv`,
+		`[VCB-Studio] Show A [NCOP][1080p].mkv`,
+	}
+	episodes := []string{"01", "02", "01", "NCOP"}
+
+	in := []string{
+		`Subs/[VCB-Studio] Show A [01][1080p].sc.ass`,
+		`Subs/SC/Other B - 01.ass`,
+		`CDs/[VCB-Studio] Show A [02].mka`,
+		`Subs/[VCB-Studio] Show A [NCOP].ass`,
+		`Subs/[VCB-Studio] Show A [03].ass`,
+	}
+
+	out1 := []int{0, 2, 1, 3, -1}
+
+	for i, data := range in {
+		o1 := out1[i]
+		r1 := matchCompanion(data, videos, episodes)
+
+		if r1 != o1 {
+			t.Errorf("Data %s: excepted %d, got %d", data, o1, r1)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/xsm1997/animeLinker/media"
+	"github.com/xsm1997/animeLinker/release"
+)
+
+const (
+	ExtraMaxDuration = 3 * time.Minute  //shorter videos of a season are NCOP, NCED or PV extras
+	MovieMinDuration = 60 * time.Minute //longer videos are movies
+
+	ClipsDir = "extras" //where short videos without special token go
+)
+
+var probeMediaFlag = flag.Bool("probe-media", true, "read the headers of videos for their length, resolution and codec")
+
+// probeVideos reads the headers of the videos of dir.
+// Other files, and videos that cannot be read, have no info.
+func probeVideos(dir string, videos []string) []*media.Info {
+	infos := make([]*media.Info, len(videos))
+	if !*probeMediaFlag {
+		return infos
+	}
+
+	for i, video := range videos {
+		if getVideosCount([]string{video}) == 0 {
+			continue
+		}
+
+		info, err := media.Probe(path.Join(dir, video))
+		if err != nil {
+			fmt.Printf("[PROBE] %s: %s.\n", video, err.Error())
+			continue
+		}
+		infos[i] = info
+	}
+
+	return infos
+}
+
+// classifyByDuration moves the short and the long regular videos of a season,
+// which have no special token in their names, to the extras and the specials.
+// A release of long videos only looks like a movie, which lowers the confidence.
+func classifyByDuration(videos, newVideos, episodes []string, regular []bool, infos []*media.Info, confidence *dirConfidence) {
+	episodeCount := 0
+	movieCount := 0
+	for i, info := range infos {
+		if !regular[i] || info == nil || info.Duration == 0 {
+			continue
+		}
+
+		if info.Duration > MovieMinDuration {
+			movieCount++
+		} else if info.Duration >= ExtraMaxDuration {
+			episodeCount++
+		}
+	}
+
+	if episodeCount == 0 {
+		if movieCount > 0 {
+			confidence.lower(release.ConfidenceLow, fmt.Sprintf("videos longer than %d minutes, a movie", int(MovieMinDuration.Minutes())))
+		}
+		return
+	}
+
+	//movies are numbered after the specials
+	movies := 0
+	for i, episode := range episodes {
+		dir, _ := getSplitPath(newVideos[i])
+		if n, err := strconv.Atoi(episode); err == nil && dir == parser.SpecialsDir && n > movies {
+			movies = n
+		}
+	}
+
+	clips := 0
+	for i, info := range infos {
+		if !regular[i] || info == nil || info.Duration == 0 {
+			continue
+		}
+
+		dir := ""
+		if info.Duration < ExtraMaxDuration {
+			dir = ClipsDir
+			clips++
+			if episodes[i] == "" {
+				episodes[i] = fmt.Sprintf("Extra%d", clips)
+			}
+		} else if info.Duration > MovieMinDuration {
+			dir = parser.SpecialsDir
+			if episodes[i] == "" {
+				movies++
+				episodes[i] = fmt.Sprintf("%02d", movies)
+			}
+		}
+		if dir == "" {
+			continue
+		}
+
+		fmt.Printf("[DURATION] %s is %s long, linked into %s.\n", videos[i], info.Duration.Round(time.Second), dir)
+
+		_, name := getSplitPath(newVideos[i])
+		newVideos[i] = path.Join(dir, name)
+		regular[i] = false
+	}
+}
+
+// addMediaFields fills the template fields missing from the names with the headers of the video.
+func addMediaFields(fields map[string]string, info *media.Info) {
+	if info == nil {
+		return
+	}
+
+	if fields["resolution"] == "" {
+		fields["resolution"] = info.Resolution()
+	}
+	if fields["codec"] == "" {
+		fields["codec"] = info.VideoCodec()
+	}
+}
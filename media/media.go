@@ -0,0 +1,142 @@
+// Package media reads the headers of video files, without ffprobe.
+// It knows Matroska, MP4 and MPEG-TS/M2TS, and finds the duration,
+// the resolution, the codecs and the languages of the tracks.
+package media
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	TrackVideo    = "video"
+	TrackAudio    = "audio"
+	TrackSubtitle = "subtitle"
+)
+
+// ErrUnknownFormat is returned for files that are no known container.
+var ErrUnknownFormat = errors.New("unknown media format")
+
+// Track is one stream of a media file.
+type Track struct {
+	Type     string //video, audio or subtitle
+	Codec    string //like HEVC, FLAC or PGS, or the container id of unknown codecs
+	Language string //like "jpn" or "zh-Hans", "" when unknown
+}
+
+// Info is what the headers of a media file tell.
+// Fields not found are left empty.
+type Info struct {
+	Format   string //matroska, mp4 or mpegts
+	Duration time.Duration
+	Width    int //of the first video track
+	Height   int
+	Tracks   []Track
+}
+
+// VideoCodec returns the codec of the first video track, like AVC, HEVC, AV1 or VP9.
+func (i *Info) VideoCodec() string {
+	for _, track := range i.Tracks {
+		if track.Type == TrackVideo {
+			return track.Codec
+		}
+	}
+
+	return ""
+}
+
+// Resolution returns the resolution like in release names, "1080p" for 1920x1080.
+// The width counts for wide movies, 1920x800 is 1080p too.
+func (i *Info) Resolution() string {
+	switch {
+	case i.Width == 0 || i.Height == 0:
+		return ""
+	case i.Width >= 3800 || i.Height >= 2100:
+		return "2160p"
+	case i.Width >= 2500 || i.Height >= 1400:
+		return "1440p"
+	case i.Width >= 1900 || i.Height >= 1000:
+		return "1080p"
+	case i.Width >= 1260 || i.Height >= 700:
+		return "720p"
+	}
+
+	return strconv.Itoa(i.Height) + "p"
+}
+
+// Languages returns the known languages of the tracks of type, in order.
+func (i *Info) Languages(trackType string) []string {
+	languages := make([]string, 0)
+	for _, track := range i.Tracks {
+		if track.Type == trackType && track.Language != "" {
+			languages = append(languages, track.Language)
+		}
+	}
+
+	return languages
+}
+
+// Probe reads the headers of a media file.
+func Probe(file string) (*Info, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ProbeReader(f)
+}
+
+// ProbeReader reads the headers of a media file from r.
+func ProbeReader(r io.ReadSeeker) (*Info, error) {
+	head := make([]byte, 2*tsPacketSize+8)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	head = head[:n]
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(head, ebmlMagic):
+		return probeMatroska(r)
+	case len(head) >= 8 && mp4FirstBoxes[string(head[4:8])]:
+		return probeMP4(r)
+	case findTSPacketSize(head) > 0:
+		return probeTS(r)
+	}
+
+	return nil, ErrUnknownFormat
+}
+
+// readAt reads up to size bytes at offset, fewer at the end of r.
+func readAt(r io.ReadSeeker, offset int64, size int) ([]byte, error) {
+	if _, err := r.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, size)
+	n, err := io.ReadFull(r, data)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+
+	return data[:n], nil
+}
+
+// getUint reads a big endian unsigned integer of up to 8 bytes.
+func getUint(data []byte) uint64 {
+	var value uint64
+	for _, b := range data {
+		value = value<<8 | uint64(b)
+	}
+
+	return value
+}
@@ -0,0 +1,226 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+// ebml builds a matroska element with a 8 bytes size.
+func ebml(id uint64, body ...[]byte) []byte {
+	data := make([]byte, 0)
+	for i := 56; i >= 0; i -= 8 {
+		if b := byte(id >> uint(i)); b != 0 || len(data) > 0 {
+			data = append(data, b)
+		}
+	}
+
+	size := make([]byte, 8)
+	binary.BigEndian.PutUint64(size, uint64(len(bytes.Join(body, nil))))
+	size[0] = 0x01
+
+	return append(append(data, size...), bytes.Join(body, nil)...)
+}
+
+func uintBytes(value uint64, size int) []byte {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, value)
+	return data[8-size:]
+}
+
+// box builds a mp4 box.
+func box(boxType string, body ...[]byte) []byte {
+	data := bytes.Join(body, nil)
+	return append(append(uintBytes(uint64(len(data)+8), 4), boxType...), data...)
+}
+
+// bitWriter writes the bits of a sps.
+type bitWriter struct {
+	data []byte
+	bits int
+}
+
+func (w *bitWriter) u(n int, value uint64) {
+	for i := n - 1; i >= 0; i-- {
+		if w.bits%8 == 0 {
+			w.data = append(w.data, 0)
+		}
+		w.data[len(w.data)-1] |= byte(value>>uint(i)&1) << (7 - uint(w.bits%8))
+		w.bits++
+	}
+}
+
+func (w *bitWriter) ue(value int) {
+	n := 0
+	for v := value + 1; v > 1; v >>= 1 {
+		n++
+	}
+	w.u(n, 0)
+	w.u(n+1, uint64(value+1))
+}
+
+func buildMatroska() []byte {
+	duration := make([]byte, 8)
+	binary.BigEndian.PutUint64(duration, math.Float64bits(1440000))
+
+	return bytes.Join([][]byte{
+		ebml(0x1A45DFA3, ebml(0x4282, []byte("matroska"))),
+		ebml(ebmlSegment,
+			ebml(0x114D9B74),
+			ebml(ebmlInfo, ebml(ebmlTimecode, uintBytes(1000000, 3)), ebml(ebmlDuration, duration)),
+			ebml(ebmlTracks,
+				ebml(ebmlTrackEntry, ebml(ebmlTrackType, []byte{1}), ebml(ebmlCodecID, []byte("V_MPEGH/ISO/HEVC")),
+					ebml(ebmlVideo, ebml(ebmlPixelWidth, uintBytes(1920, 2)), ebml(ebmlPixelHeight, uintBytes(1080, 2)))),
+				ebml(ebmlTrackEntry, ebml(ebmlTrackType, []byte{2}), ebml(ebmlCodecID, []byte("A_FLAC")), ebml(ebmlLanguage, []byte("jpn"))),
+				ebml(ebmlTrackEntry, ebml(ebmlTrackType, []byte{17}), ebml(ebmlCodecID, []byte("S_TEXT/ASS")),
+					ebml(ebmlLanguage, []byte("chi")), ebml(ebmlLanguageIETF, []byte("zh-Hans"))),
+			),
+			ebml(ebmlCluster, make([]byte, 64)),
+		),
+	}, nil)
+}
+
+func buildMP4() []byte {
+	fullBox := func(data ...[]byte) []byte {
+		return bytes.Join(append([][]byte{{0, 0, 0, 0}}, data...), nil)
+	}
+	track := func(handler, fourcc string, language string, width, height uint64) []byte {
+		code := uint64(0)
+		for _, c := range language {
+			code = code<<5 | uint64(c-0x60)
+		}
+
+		return box("trak",
+			box("tkhd", fullBox(make([]byte, 72), uintBytes(width<<16, 4), uintBytes(height<<16, 4))),
+			box("mdia",
+				box("mdhd", fullBox(make([]byte, 16), uintBytes(code, 2), make([]byte, 2))),
+				box("hdlr", fullBox(make([]byte, 4), []byte(handler), make([]byte, 12))),
+				box("minf", box("stbl", box("stsd", fullBox(uintBytes(1, 4), box(fourcc, make([]byte, 8)))))),
+			),
+		)
+	}
+
+	return bytes.Join([][]byte{
+		box("ftyp", []byte("isom"), make([]byte, 4)),
+		box("mdat", make([]byte, 1024)),
+		box("moov",
+			box("mvhd", fullBox(make([]byte, 8), uintBytes(1000, 4), uintBytes(150000, 4), make([]byte, 80))),
+			track("vide", "avc1", "und", 1280, 720),
+			track("soun", "mp4a", "jpn", 0, 0),
+		),
+	}, nil)
+}
+
+func buildTS(packetSize int) []byte {
+	packets := make([][]byte, 0)
+	packet := func(pid int, start bool, pcr int64, payload []byte) {
+		data := []byte{tsSyncByte, byte(pid >> 8 & 0x1F), byte(pid), 0x10}
+		if start {
+			data[1] |= 0x40
+		}
+		if pcr >= 0 {
+			data[3] |= 0x20
+			data = append(data, 7, 0x10, byte(pcr>>25), byte(pcr>>17), byte(pcr>>9), byte(pcr>>1), byte(pcr<<7), 0)
+		}
+		data = append(data, payload...)
+		for len(data) < tsPacketSize {
+			data = append(data, 0xFF)
+		}
+
+		packets = append(packets, append(make([]byte, packetSize-tsPacketSize), data...))
+	}
+	section := func(tableID byte, body []byte) []byte {
+		length := len(body) + 5 + 4
+		header := []byte{0, tableID, 0xB0 | byte(length>>8), byte(length), 0, 1, 0xC1, 0, 0}
+		return append(append(header, body...), 0, 0, 0, 0)
+	}
+
+	//hevc sps of 1920x1080, main profile
+	w := &bitWriter{}
+	w.u(4, 0)
+	w.u(3, 0)
+	w.u(1, 1)
+	w.u(32, 0x01600000)
+	w.u(32, 0)
+	w.u(32, 0x5D)
+	w.ue(0)
+	w.ue(1)
+	w.ue(1920)
+	w.ue(1088)
+	w.u(1, 1)
+	w.ue(0)
+	w.ue(0)
+	w.ue(0)
+	w.ue(4)
+	w.u(8, 0xFF)
+	sps := []byte{0, 0, 0, 1, 33 << 1, 1}
+	zeros := 0
+	for _, b := range w.data {
+		//escape 00 00 0x like the encoders
+		if zeros >= 2 && b <= 3 {
+			sps = append(sps, 3)
+			zeros = 0
+		}
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+		sps = append(sps, b)
+	}
+	pes := append([]byte{0, 0, 1, 0xE0, 0, 0, 0x80, 0x80, 5, 0, 0, 0, 0, 0}, sps...)
+	pes = append(pes, 0, 0, 0, 1, 1<<1, 1)
+
+	packet(0, true, -1, section(0, []byte{0, 1, 0xE1, 0x00}))
+	packet(0x100, true, -1, section(2, []byte{
+		0xE1, 0x01, 0xF0, 0,
+		0x24, 0xE1, 0x11, 0xF0, 0,
+		0x0F, 0xE1, 0x12, 0xF0, 6, 0x0A, 4, 'j', 'p', 'n', 0,
+	}))
+	packet(0x101, false, 0, nil)
+	packet(0x111, true, -1, pes)
+	packet(0x111, false, -1, nil)
+	packet(0x101, false, 90000*100, nil)
+
+	return bytes.Join(packets, nil)
+}
+
+func TestProbe(t *testing.T) {
+	in := [][]byte{
+		buildMatroska(),
+		buildMP4(),
+		buildTS(188),
+		buildTS(192),
+		[]byte("[Script Info]\nTitle: Show\n"),
+	}
+
+	out1 := []string{
+		`matroska 24m0s 1920x1080 1080p HEVC [jpn] [zh-Hans]`,
+		`mp4 2m30s 1280x720 720p AVC [jpn] []`,
+		`mpegts 1m40s 1920x1080 1080p HEVC [jpn] []`,
+		`mpegts 1m40s 1920x1080 1080p HEVC [jpn] []`,
+		`unknown media format`,
+	}
+
+	for i, data := range in {
+		o1 := out1[i]
+
+		var r1 string
+		info, err := ProbeReader(bytes.NewReader(data))
+		if err != nil {
+			r1 = err.Error()
+		} else {
+			r1 = strings.Join([]string{
+				info.Format, info.Duration.String(), fmt.Sprintf("%dx%d", info.Width, info.Height), info.Resolution(), info.VideoCodec(),
+				fmt.Sprint(info.Languages(TrackAudio)), fmt.Sprint(info.Languages(TrackSubtitle)),
+			}, " ")
+		}
+
+		if r1 != o1 {
+			t.Errorf("Data %d: excepted %s, got %s", i, o1, r1)
+		}
+	}
+}
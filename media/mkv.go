@@ -0,0 +1,247 @@
+package media
+
+import (
+	"errors"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+const (
+	ebmlSegment      = 0x18538067
+	ebmlInfo         = 0x1549A966
+	ebmlTimecode     = 0x2AD7B1
+	ebmlDuration     = 0x4489
+	ebmlTracks       = 0x1654AE6B
+	ebmlTrackEntry   = 0xAE
+	ebmlTrackType    = 0x83
+	ebmlCodecID      = 0x86
+	ebmlLanguage     = 0x22B59C
+	ebmlLanguageIETF = 0x22B59D
+	ebmlVideo        = 0xE0
+	ebmlPixelWidth   = 0xB0
+	ebmlPixelHeight  = 0xBA
+	ebmlCluster      = 0x1F43B675
+
+	ebmlUnknownSize = -1
+	ebmlMaxElement  = 16 << 20 //info and tracks are a few kilobytes
+)
+
+var (
+	ebmlMagic = []byte{0x1A, 0x45, 0xDF, 0xA3}
+
+	errBadEBML = errors.New("bad matroska element")
+
+	mkvTrackTypes = map[uint64]string{1: TrackVideo, 2: TrackAudio, 17: TrackSubtitle}
+
+	//codec ids by prefix, A_AAC/MPEG4/LC is AAC
+	mkvCodecs = []struct{ id, codec string }{
+		{"V_MPEG4/ISO/AVC", "AVC"}, {"V_MPEGH/ISO/HEVC", "HEVC"}, {"V_AV1", "AV1"}, {"V_VP9", "VP9"},
+		{"V_MPEG2", "MPEG2"}, {"V_MS/VFW/FOURCC", "VFW"},
+		{"A_FLAC", "FLAC"}, {"A_AAC", "AAC"}, {"A_AC3", "AC3"}, {"A_EAC3", "EAC3"}, {"A_DTS", "DTS"},
+		{"A_TRUEHD", "TrueHD"}, {"A_OPUS", "Opus"}, {"A_VORBIS", "Vorbis"}, {"A_MPEG/L3", "MP3"}, {"A_PCM", "LPCM"},
+		{"S_TEXT/ASS", "ASS"}, {"S_TEXT/SSA", "ASS"}, {"S_TEXT/UTF8", "SRT"}, {"S_HDMV/PGS", "PGS"}, {"S_VOBSUB", "VobSub"},
+	}
+)
+
+// readVint reads the variable length integer at the start of data, keeping the length marker for ids.
+func readVint(data []byte, keepMarker bool) (value uint64, length int, err error) {
+	if len(data) == 0 || data[0] == 0 {
+		return 0, 0, errBadEBML
+	}
+
+	length = 1
+	for mask := byte(0x80); data[0]&mask == 0; mask >>= 1 {
+		length++
+	}
+	if len(data) < length {
+		return 0, 0, errBadEBML
+	}
+
+	value = getUint(data[:length])
+	if !keepMarker {
+		value &^= 1 << (uint(length) * 7)
+	}
+
+	return value, length, nil
+}
+
+// readElementHeader reads the id and the size of an element, ebmlUnknownSize for a size of all ones.
+func readElementHeader(data []byte) (id uint64, size int64, length int, err error) {
+	id, idLength, err := readVint(data, true)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	value, sizeLength, err := readVint(data[idLength:], false)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	size = int64(value)
+	if value == 1<<(uint(sizeLength)*7)-1 {
+		size = ebmlUnknownSize
+	}
+
+	return id, size, idLength + sizeLength, nil
+}
+
+// forEachElement calls f for the children of an element read into data.
+func forEachElement(data []byte, f func(id uint64, body []byte)) {
+	for len(data) > 0 {
+		id, size, length, err := readElementHeader(data)
+		if err != nil || size < 0 || int64(len(data)-length) < size {
+			return
+		}
+
+		f(id, data[length:int64(length)+size])
+		data = data[int64(length)+size:]
+	}
+}
+
+func getFloat(data []byte) float64 {
+	switch len(data) {
+	case 4:
+		return float64(math.Float32frombits(uint32(getUint(data))))
+	case 8:
+		return math.Float64frombits(getUint(data))
+	}
+
+	return 0
+}
+
+func getMatroskaCodec(id string) string {
+	for _, codec := range mkvCodecs {
+		if strings.HasPrefix(id, codec.id) {
+			return codec.codec
+		}
+	}
+
+	return id
+}
+
+func parseMatroskaTrack(info *Info, data []byte) {
+	track := Track{Language: "eng"} //the default of matroska
+	ietf := ""
+	width, height := 0, 0
+
+	forEachElement(data, func(id uint64, body []byte) {
+		switch id {
+		case ebmlTrackType:
+			track.Type = mkvTrackTypes[getUint(body)]
+		case ebmlCodecID:
+			track.Codec = getMatroskaCodec(string(body))
+		case ebmlLanguage:
+			track.Language = strings.TrimRight(string(body), "\x00")
+		case ebmlLanguageIETF:
+			ietf = strings.TrimRight(string(body), "\x00")
+		case ebmlVideo:
+			forEachElement(body, func(id uint64, body []byte) {
+				switch id {
+				case ebmlPixelWidth:
+					width = int(getUint(body))
+				case ebmlPixelHeight:
+					height = int(getUint(body))
+				}
+			})
+		}
+	})
+
+	if ietf != "" {
+		track.Language = ietf
+	}
+	if track.Language == "und" {
+		track.Language = ""
+	}
+
+	if track.Type == "" {
+		return
+	}
+	if track.Type == TrackVideo && info.Width == 0 {
+		info.Width, info.Height = width, height
+	}
+
+	info.Tracks = append(info.Tracks, track)
+}
+
+// probeMatroska reads the segment info and the tracks, which come before the clusters.
+func probeMatroska(r io.ReadSeeker) (*Info, error) {
+	info := &Info{Format: "matroska"}
+
+	offset := int64(0)
+	end := int64(math.MaxInt64)
+	scale := uint64(1000000)
+	duration := 0.0
+	foundInfo, foundTracks := false, false
+
+	for offset < end && !(foundInfo && foundTracks) {
+		head, err := readAt(r, offset, 12)
+		if err != nil {
+			return nil, err
+		}
+		if len(head) == 0 {
+			break
+		}
+
+		id, size, length, err := readElementHeader(head)
+		if err != nil {
+			return nil, err
+		}
+		offset += int64(length)
+
+		switch id {
+		case ebmlSegment:
+			//enter the segment
+			if size != ebmlUnknownSize {
+				end = offset + size
+			}
+			continue
+		case ebmlInfo, ebmlTracks:
+			if size < 0 || size > ebmlMaxElement {
+				return nil, errBadEBML
+			}
+
+			body, err := readAt(r, offset, int(size))
+			if err != nil {
+				return nil, err
+			}
+
+			if id == ebmlInfo {
+				foundInfo = true
+				forEachElement(body, func(id uint64, body []byte) {
+					switch id {
+					case ebmlTimecode:
+						scale = getUint(body)
+					case ebmlDuration:
+						duration = getFloat(body)
+					}
+				})
+			} else {
+				foundTracks = true
+				forEachElement(body, func(id uint64, body []byte) {
+					if id == ebmlTrackEntry {
+						parseMatroskaTrack(info, body)
+					}
+				})
+			}
+		case ebmlCluster:
+			//the headers are written before the clusters
+			end = offset
+			continue
+		}
+
+		if size == ebmlUnknownSize {
+			break
+		}
+		offset += size
+	}
+
+	if !foundInfo && !foundTracks {
+		return nil, errBadEBML
+	}
+
+	info.Duration = time.Duration(duration * float64(scale))
+
+	return info, nil
+}
@@ -0,0 +1,239 @@
+package media
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+)
+
+const (
+	mp4MaxMoov = 64 << 20 //the sample tables of long videos take a few megabytes
+)
+
+var (
+	errBadMP4 = errors.New("bad mp4 box")
+
+	//boxes a mp4 file may start with
+	mp4FirstBoxes = map[string]bool{"ftyp": true, "moov": true, "mdat": true, "free": true, "skip": true, "wide": true}
+
+	mp4Handlers = map[string]string{"vide": TrackVideo, "soun": TrackAudio, "sbtl": TrackSubtitle, "subt": TrackSubtitle, "text": TrackSubtitle}
+
+	mp4Codecs = map[string]string{
+		"avc1": "AVC", "avc3": "AVC", "hvc1": "HEVC", "hev1": "HEVC", "av01": "AV1", "vp09": "VP9",
+		"mp4a": "AAC", "ac-3": "AC3", "ec-3": "EAC3", "fLaC": "FLAC", "Opus": "Opus", "alac": "ALAC",
+		"tx3g": "TX3G", "wvtt": "WebVTT", "stpp": "TTML",
+	}
+)
+
+// readBoxHeader reads the size and the type of a box, size 0 meaning until the end.
+func readBoxHeader(data []byte) (size int64, boxType string, length int, err error) {
+	if len(data) < 8 {
+		return 0, "", 0, errBadMP4
+	}
+
+	size = int64(binary.BigEndian.Uint32(data))
+	boxType = string(data[4:8])
+	length = 8
+
+	if size == 1 {
+		if len(data) < 16 {
+			return 0, "", 0, errBadMP4
+		}
+		size = int64(binary.BigEndian.Uint64(data[8:]))
+		length = 16
+	}
+
+	if size != 0 && size < int64(length) {
+		return 0, "", 0, errBadMP4
+	}
+
+	return size, boxType, length, nil
+}
+
+// forEachBox calls f for the boxes read into data.
+func forEachBox(data []byte, f func(boxType string, body []byte)) {
+	for len(data) > 0 {
+		size, boxType, length, err := readBoxHeader(data)
+		if err != nil || size > int64(len(data)) {
+			return
+		}
+		if size == 0 {
+			size = int64(len(data))
+		}
+
+		f(boxType, data[length:size])
+		data = data[size:]
+	}
+}
+
+// readFullBox skips the version and flags of a full box, returning the version.
+func readFullBox(body []byte) (version byte, rest []byte, ok bool) {
+	if len(body) < 4 {
+		return 0, nil, false
+	}
+
+	return body[0], body[4:], true
+}
+
+// getMP4Language decodes the packed ISO 639-2 code of mdhd, three letters of 5 bits.
+func getMP4Language(code uint16) string {
+	if code == 0 || code == 0x7FFF {
+		return ""
+	}
+
+	language := string([]byte{
+		byte(code>>10&0x1F) + 0x60,
+		byte(code>>5&0x1F) + 0x60,
+		byte(code&0x1F) + 0x60,
+	})
+	if language == "und" {
+		return ""
+	}
+
+	return language
+}
+
+func parseMP4Track(info *Info, data []byte) {
+	var track Track
+	width, height := 0, 0
+
+	forEachBox(data, func(boxType string, body []byte) {
+		switch boxType {
+		case "tkhd":
+			version, body, ok := readFullBox(body)
+			//width and height are fixed point 16.16 numbers at the end
+			offset := 72
+			if version == 1 {
+				offset = 84
+			}
+			if ok && len(body) >= offset+8 {
+				width = int(binary.BigEndian.Uint32(body[offset:]) >> 16)
+				height = int(binary.BigEndian.Uint32(body[offset+4:]) >> 16)
+			}
+		case "mdia":
+			forEachBox(body, func(boxType string, body []byte) {
+				switch boxType {
+				case "mdhd":
+					version, body, ok := readFullBox(body)
+					offset := 16
+					if version == 1 {
+						offset = 28
+					}
+					if ok && len(body) >= offset+2 {
+						track.Language = getMP4Language(binary.BigEndian.Uint16(body[offset:]))
+					}
+				case "hdlr":
+					if _, body, ok := readFullBox(body); ok && len(body) >= 8 {
+						track.Type = mp4Handlers[string(body[4:8])]
+					}
+				case "minf":
+					track.Codec = getMP4Codec(body)
+				}
+			})
+		}
+	})
+
+	if track.Type == "" {
+		return
+	}
+	if track.Type == TrackVideo && info.Width == 0 {
+		info.Width, info.Height = width, height
+	}
+
+	info.Tracks = append(info.Tracks, track)
+}
+
+// getMP4Codec returns the codec of the first sample entry, in minf/stbl/stsd.
+func getMP4Codec(minf []byte) string {
+	codec := ""
+	forEachBox(minf, func(boxType string, body []byte) {
+		if boxType != "stbl" {
+			return
+		}
+
+		forEachBox(body, func(boxType string, body []byte) {
+			if boxType != "stsd" {
+				return
+			}
+
+			//entry count, then the sample entries
+			if _, body, ok := readFullBox(body); ok && len(body) >= 12 {
+				fourcc := string(body[8:12])
+				if codec = mp4Codecs[fourcc]; codec == "" {
+					codec = fourcc
+				}
+			}
+		})
+	})
+
+	return codec
+}
+
+// probeMP4 finds the moov box, which may come after the media data, and reads its headers.
+func probeMP4(r io.ReadSeeker) (*Info, error) {
+	offset := int64(0)
+	for {
+		head, err := readAt(r, offset, 16)
+		if err != nil {
+			return nil, err
+		}
+		if len(head) == 0 {
+			return nil, errBadMP4
+		}
+
+		size, boxType, length, err := readBoxHeader(head)
+		if err != nil {
+			return nil, err
+		}
+
+		if boxType == "moov" {
+			if size == 0 || size > mp4MaxMoov {
+				return nil, errBadMP4
+			}
+
+			body, err := readAt(r, offset+int64(length), int(size)-length)
+			if err != nil {
+				return nil, err
+			}
+
+			return parseMP4Movie(body), nil
+		}
+
+		if size == 0 {
+			return nil, errBadMP4
+		}
+		offset += size
+	}
+}
+
+func parseMP4Movie(moov []byte) *Info {
+	info := &Info{Format: "mp4"}
+
+	forEachBox(moov, func(boxType string, body []byte) {
+		switch boxType {
+		case "mvhd":
+			version, body, ok := readFullBox(body)
+			if !ok {
+				return
+			}
+
+			var timescale, duration uint64
+			if version == 1 && len(body) >= 28 {
+				timescale = uint64(binary.BigEndian.Uint32(body[16:]))
+				duration = binary.BigEndian.Uint64(body[20:])
+			} else if version == 0 && len(body) >= 16 {
+				timescale = uint64(binary.BigEndian.Uint32(body[8:]))
+				duration = uint64(binary.BigEndian.Uint32(body[12:]))
+			}
+
+			if timescale > 0 {
+				info.Duration = time.Duration(float64(duration) / float64(timescale) * float64(time.Second))
+			}
+		case "trak":
+			parseMP4Track(info, body)
+		}
+	})
+
+	return info
+}
@@ -0,0 +1,257 @@
+package media
+
+// The resolution of MPEG-TS video is only in the sequence parameter set of the stream.
+
+// bitReader reads the bits of a nal unit, with the emulation prevention bytes removed.
+type bitReader struct {
+	data []byte
+	pos  int
+	bad  bool //read past the end
+}
+
+func newBitReader(nal []byte) *bitReader {
+	//00 00 03 escapes 00 00 0x in the payload
+	data := make([]byte, 0, len(nal))
+	zeros := 0
+	for _, b := range nal {
+		if zeros >= 2 && b == 3 {
+			zeros = 0
+			continue
+		}
+
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+		data = append(data, b)
+	}
+
+	return &bitReader{data: data}
+}
+
+func (r *bitReader) u(n int) uint64 {
+	var value uint64
+	for i := 0; i < n; i++ {
+		if r.pos >= len(r.data)*8 {
+			r.bad = true
+			return 0
+		}
+
+		bit := r.data[r.pos/8] >> (7 - uint(r.pos%8)) & 1
+		value = value<<1 | uint64(bit)
+		r.pos++
+	}
+
+	return value
+}
+
+func (r *bitReader) flag() bool {
+	return r.u(1) == 1
+}
+
+// ue reads an exp-golomb coded unsigned integer.
+func (r *bitReader) ue() int {
+	zeros := 0
+	for !r.flag() {
+		zeros++
+		if zeros > 31 || r.bad {
+			r.bad = true
+			return 0
+		}
+	}
+
+	return int(1<<uint(zeros)-1) + int(r.u(zeros))
+}
+
+// se reads an exp-golomb coded signed integer.
+func (r *bitReader) se() int {
+	k := r.ue()
+	if k%2 == 1 {
+		return (k + 1) / 2
+	}
+
+	return -k / 2
+}
+
+var (
+	//profiles of avc with the chroma format and the scaling matrices in the sps
+	avcHighProfiles = map[uint64]bool{100: true, 110: true, 122: true, 244: true, 44: true, 83: true, 86: true, 118: true, 128: true, 138: true, 139: true, 134: true, 135: true}
+)
+
+// parseAVCSPS returns the size of the pictures of an avc sps, without the nal header.
+func parseAVCSPS(nal []byte) (width, height int, ok bool) {
+	r := newBitReader(nal)
+
+	profile := r.u(8)
+	r.u(16) //constraints and level
+	r.ue()  //sps id
+
+	chroma := 1
+	if avcHighProfiles[profile] {
+		chroma = r.ue()
+		if chroma == 3 {
+			r.u(1)
+		}
+		r.ue() //bit depths
+		r.ue()
+		r.u(1)
+		if r.flag() {
+			lists := 8
+			if chroma == 3 {
+				lists = 12
+			}
+			for i := 0; i < lists && !r.bad; i++ {
+				if !r.flag() {
+					continue
+				}
+
+				size := 16
+				if i >= 6 {
+					size = 64
+				}
+				last, next := 8, 8
+				for j := 0; j < size && !r.bad; j++ {
+					if next != 0 {
+						next = (last + r.se() + 256) % 256
+					}
+					if next != 0 {
+						last = next
+					}
+				}
+			}
+		}
+	}
+
+	r.ue() //log2 max frame num
+	switch r.ue() {
+	case 0:
+		r.ue()
+	case 1:
+		r.u(1)
+		r.se()
+		r.se()
+		for n := r.ue(); n > 0 && !r.bad; n-- {
+			r.se()
+		}
+	}
+	r.ue() //reference frames
+	r.u(1)
+
+	widthMbs := r.ue() + 1
+	heightMaps := r.ue() + 1
+	frameMbsOnly := 1
+	if !r.flag() {
+		frameMbsOnly = 0
+		r.u(1)
+	}
+	r.u(1)
+
+	width = widthMbs * 16
+	height = (2 - frameMbsOnly) * heightMaps * 16
+
+	if r.flag() {
+		left, right, top, bottom := r.ue(), r.ue(), r.ue(), r.ue()
+
+		cropX, cropY := 1, 2-frameMbsOnly
+		if chroma == 1 || chroma == 2 {
+			cropX = 2
+		}
+		if chroma == 1 {
+			cropY *= 2
+		}
+		width -= (left + right) * cropX
+		height -= (top + bottom) * cropY
+	}
+
+	return width, height, !r.bad && width > 0 && height > 0
+}
+
+// parseHEVCSPS returns the size of the pictures of a hevc sps, without the nal header.
+func parseHEVCSPS(nal []byte) (width, height int, ok bool) {
+	r := newBitReader(nal)
+
+	r.u(4) //vps id
+	subLayers := int(r.u(3))
+	r.u(1)
+
+	//profile, tier and level
+	r.u(32)
+	r.u(32)
+	r.u(32)
+	subProfiles := make([]bool, subLayers)
+	subLevels := make([]bool, subLayers)
+	for i := 0; i < subLayers; i++ {
+		subProfiles[i] = r.flag()
+		subLevels[i] = r.flag()
+	}
+	if subLayers > 0 {
+		r.u(2 * (8 - subLayers))
+	}
+	for i := 0; i < subLayers; i++ {
+		if subProfiles[i] {
+			r.u(32)
+			r.u(32)
+			r.u(24)
+		}
+		if subLevels[i] {
+			r.u(8)
+		}
+	}
+
+	r.ue() //sps id
+	chroma := r.ue()
+	if chroma == 3 {
+		r.u(1)
+	}
+
+	width = r.ue()
+	height = r.ue()
+
+	if r.flag() {
+		left, right, top, bottom := r.ue(), r.ue(), r.ue(), r.ue()
+
+		subWidth, subHeight := 1, 1
+		if chroma == 1 || chroma == 2 {
+			subWidth = 2
+		}
+		if chroma == 1 {
+			subHeight = 2
+		}
+		width -= (left + right) * subWidth
+		height -= (top + bottom) * subHeight
+	}
+
+	return width, height, !r.bad && width > 0 && height > 0
+}
+
+// findSPS finds the sps in an elementary stream of codec, or the sequence header of mpeg2,
+// and returns the size of the pictures.
+func findSPS(data []byte, codec string) (width, height int, ok bool) {
+	for i := 0; i+4 < len(data); i++ {
+		if data[i] != 0 || data[i+1] != 0 || data[i+2] != 1 {
+			continue
+		}
+
+		nal := data[i+3:]
+		//the sps ends at the next start code, or is cut short
+		for j := 0; j+2 < len(nal); j++ {
+			if nal[j] == 0 && nal[j+1] == 0 && nal[j+2] <= 1 {
+				nal = nal[:j]
+				break
+			}
+		}
+
+		switch {
+		case codec == "AVC" && len(nal) > 1 && nal[0]&0x1F == 7:
+			return parseAVCSPS(nal[1:])
+		case codec == "HEVC" && len(nal) > 2 && nal[0]>>1&0x3F == 33:
+			return parseHEVCSPS(nal[2:])
+		case (codec == "MPEG2" || codec == "MPEG1") && len(nal) >= 4 && nal[0] == 0xB3:
+			//the sequence header starts with the 12 bits width and height
+			return int(nal[1])<<4 | int(nal[2])>>4, int(nal[2]&0x0F)<<8 | int(nal[3]), true
+		}
+	}
+
+	return 0, 0, false
+}
@@ -0,0 +1,272 @@
+package media
+
+import (
+	"errors"
+	"io"
+	"time"
+)
+
+const (
+	tsPacketSize = 188
+	tsSyncByte   = 0x47
+	tsHeadSize   = 4 << 20 //the tables and the first pictures
+	tsTailSize   = 2 << 20 //for the last pcr
+	tsMaxPES     = 256 << 10
+
+	pcrClock = 90000 //the 33 bits base of the pcr, in 90 kHz
+	pcrWrap  = 1 << 33
+)
+
+var (
+	errBadTS = errors.New("bad mpeg-ts stream")
+
+	tsStreamTypes = map[byte]Track{
+		0x01: {Type: TrackVideo, Codec: "MPEG1"}, 0x02: {Type: TrackVideo, Codec: "MPEG2"},
+		0x1B: {Type: TrackVideo, Codec: "AVC"}, 0x24: {Type: TrackVideo, Codec: "HEVC"}, 0xEA: {Type: TrackVideo, Codec: "VC1"},
+		0x03: {Type: TrackAudio, Codec: "MP3"}, 0x04: {Type: TrackAudio, Codec: "MP3"},
+		0x0F: {Type: TrackAudio, Codec: "AAC"}, 0x11: {Type: TrackAudio, Codec: "AAC"},
+		0x80: {Type: TrackAudio, Codec: "LPCM"}, 0x81: {Type: TrackAudio, Codec: "AC3"}, 0x82: {Type: TrackAudio, Codec: "DTS"},
+		0x83: {Type: TrackAudio, Codec: "TrueHD"}, 0x84: {Type: TrackAudio, Codec: "EAC3"}, 0x85: {Type: TrackAudio, Codec: "DTS"},
+		0x86: {Type: TrackAudio, Codec: "DTS"}, 0xA1: {Type: TrackAudio, Codec: "EAC3"}, 0xA2: {Type: TrackAudio, Codec: "DTS"},
+		0x90: {Type: TrackSubtitle, Codec: "PGS"}, 0x92: {Type: TrackSubtitle, Codec: "TextST"},
+	}
+)
+
+// findTSPacketSize returns 188 for MPEG-TS, 192 for M2TS with its timecode before each packet, or 0.
+func findTSPacketSize(data []byte) int {
+	for _, size := range []int{tsPacketSize, tsPacketSize + 4} {
+		sync := size - tsPacketSize
+		if len(data) > sync+size && data[sync] == tsSyncByte && data[sync+size] == tsSyncByte {
+			return size
+		}
+	}
+
+	return 0
+}
+
+type tsParser struct {
+	info       *Info
+	packetSize int
+
+	pmtPID   int
+	pcrPID   int
+	videoPID int
+	codec    string //of the video
+	pes      []byte //start of the video stream, for the sps
+
+	firstPCR int64
+	lastPCR  int64
+}
+
+// forEachPacket calls f for the packets of data, which may start in the middle of a packet.
+func (t *tsParser) forEachPacket(data []byte, f func(packet []byte)) {
+	sync := t.packetSize - tsPacketSize
+
+	start := -1
+	for i := 0; i+sync+2*t.packetSize <= len(data) && i < t.packetSize; i++ {
+		if data[i+sync] == tsSyncByte && data[i+sync+t.packetSize] == tsSyncByte {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return
+	}
+
+	for i := start; i+t.packetSize <= len(data); i += t.packetSize {
+		packet := data[i+sync : i+t.packetSize]
+		if packet[0] == tsSyncByte {
+			f(packet)
+		}
+	}
+}
+
+// getSection returns the table section of a payload starting one, without the crc.
+func getSection(payload []byte) []byte {
+	if len(payload) == 0 || int(payload[0])+1 > len(payload) {
+		return nil
+	}
+
+	section := payload[1+int(payload[0]):]
+	if len(section) < 3 {
+		return nil
+	}
+
+	end := 3 + (int(section[1]&0x0F)<<8 | int(section[2])) - 4
+	if end > len(section) || end < 8 {
+		return nil
+	}
+
+	return section[:end]
+}
+
+func (t *tsParser) parsePAT(section []byte) {
+	for i := 8; i+4 <= len(section); i += 4 {
+		program := int(section[i])<<8 | int(section[i+1])
+		if program != 0 {
+			t.pmtPID = int(section[i+2]&0x1F)<<8 | int(section[i+3])
+			return
+		}
+	}
+}
+
+func (t *tsParser) parsePMT(section []byte) {
+	if len(section) < 12 {
+		return
+	}
+
+	t.pcrPID = int(section[8]&0x1F)<<8 | int(section[9])
+	i := 12 + (int(section[10]&0x0F)<<8 | int(section[11]))
+
+	for i+5 <= len(section) {
+		streamType := section[i]
+		pid := int(section[i+1]&0x1F)<<8 | int(section[i+2])
+		end := i + 5 + (int(section[i+3]&0x0F)<<8 | int(section[i+4]))
+		if end > len(section) {
+			break
+		}
+
+		track, ok := tsStreamTypes[streamType]
+		if ok {
+			//the ISO 639 language descriptor
+			for j := i + 5; j+2 <= end; j += 2 + int(section[j+1]) {
+				if section[j] == 0x0A && section[j+1] >= 3 && j+5 <= end {
+					track.Language = string(section[j+2 : j+5])
+					break
+				}
+			}
+			if track.Language == "und" {
+				track.Language = ""
+			}
+
+			if track.Type == TrackVideo && t.videoPID < 0 {
+				t.videoPID = pid
+				t.codec = track.Codec
+			}
+			t.info.Tracks = append(t.info.Tracks, track)
+		}
+
+		i = end
+	}
+}
+
+func (t *tsParser) parsePacket(packet []byte) {
+	pid := int(packet[1]&0x1F)<<8 | int(packet[2])
+	start := packet[1]&0x40 != 0
+	control := packet[3] >> 4 & 3
+	payload := packet[4:]
+
+	if control&2 != 0 {
+		length := int(payload[0])
+		if length >= 7 && payload[1]&0x10 != 0 && pid == t.pcrPID {
+			b := payload[2:7]
+			pcr := int64(b[0])<<25 | int64(b[1])<<17 | int64(b[2])<<9 | int64(b[3])<<1 | int64(b[4])>>7
+			if t.firstPCR < 0 {
+				t.firstPCR = pcr
+			}
+			t.lastPCR = pcr
+		}
+
+		if 1+length > len(payload) {
+			return
+		}
+		payload = payload[1+length:]
+	}
+	if control&1 == 0 {
+		return
+	}
+
+	switch {
+	case pid == 0 && start && t.pmtPID < 0:
+		if section := getSection(payload); section != nil {
+			t.parsePAT(section)
+		}
+	case pid == t.pmtPID && start && t.pcrPID < 0:
+		if section := getSection(payload); section != nil {
+			t.parsePMT(section)
+		}
+	case pid == t.videoPID && t.info.Width == 0:
+		if start {
+			//the sps comes first in a pes of a key frame
+			t.findSPS()
+			t.pes = t.pes[:0]
+
+			//skip the pes header
+			if len(payload) < 9 || int(payload[8])+9 > len(payload) {
+				return
+			}
+			payload = payload[9+int(payload[8]):]
+		} else if len(t.pes) == 0 {
+			return
+		}
+
+		if len(t.pes) < tsMaxPES {
+			t.pes = append(t.pes, payload...)
+		}
+	}
+}
+
+func (t *tsParser) findSPS() {
+	if len(t.pes) == 0 || t.info.Width > 0 {
+		return
+	}
+
+	if width, height, ok := findSPS(t.pes, t.codec); ok {
+		t.info.Width, t.info.Height = width, height
+	}
+}
+
+// probeTS reads the program tables and the first pictures at the start of the stream,
+// and the duration from the clock references at the start and the end.
+func probeTS(r io.ReadSeeker) (*Info, error) {
+	head, err := readAt(r, 0, tsHeadSize)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &tsParser{
+		info:       &Info{Format: "mpegts"},
+		packetSize: findTSPacketSize(head),
+		pmtPID:     -1,
+		pcrPID:     -1,
+		videoPID:   -1,
+		firstPCR:   -1,
+		lastPCR:    -1,
+	}
+	if t.packetSize == 0 {
+		return nil, errBadTS
+	}
+
+	t.forEachPacket(head, t.parsePacket)
+	t.findSPS()
+	if t.pcrPID < 0 {
+		return nil, errBadTS
+	}
+
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if size > int64(len(head)) {
+		offset := size - tsTailSize
+		if offset < int64(len(head)) {
+			offset = int64(len(head))
+		}
+
+		tail, err := readAt(r, offset, int(size-offset))
+		if err != nil {
+			return nil, err
+		}
+		t.forEachPacket(tail, t.parsePacket)
+	}
+
+	if t.firstPCR >= 0 {
+		clock := t.lastPCR - t.firstPCR
+		if clock < 0 {
+			clock += pcrWrap
+		}
+		t.info.Duration = time.Duration(clock) * time.Second / pcrClock
+	}
+
+	return t.info, nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"os"
+	"path"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+const notifyMask = syscall.IN_CREATE | syscall.IN_CLOSE_WRITE | syscall.IN_MODIFY |
+	syscall.IN_MOVED_TO | syscall.IN_MOVED_FROM | syscall.IN_DELETE
+
+// startNotify watches src and all its subdirectories with inotify.
+// The name of the top level entry of src is sent to changes on every event under it,
+// and "" when the event queue overflowed and events were lost.
+func startNotify(src string, changes chan<- string) error {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC)
+	if err != nil {
+		return err
+	}
+
+	src = path.Clean(src)
+	watches := make(map[int32]string)
+
+	var addWatch func(dir string) error
+	addWatch = func(dir string) error {
+		wd, err := syscall.InotifyAddWatch(fd, dir, notifyMask)
+		if err != nil {
+			return err
+		}
+		watches[int32(wd)] = dir
+
+		for _, name := range listReleases(dir) {
+			sub := path.Join(dir, name)
+			if info, err := os.Stat(sub); err == nil && info.IsDir() {
+				//subdirectories failing to be watched are still found by polling
+				addWatch(sub)
+			}
+		}
+
+		return nil
+	}
+
+	if err := addWatch(src); err != nil {
+		syscall.Close(fd)
+		return err
+	}
+
+	go func() {
+		buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+
+		for {
+			n, err := syscall.Read(fd, buf)
+			if err != nil {
+				if err == syscall.EINTR {
+					continue
+				}
+				return
+			}
+
+			for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+				event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+				nameBytes := buf[offset+syscall.SizeofInotifyEvent : offset+syscall.SizeofInotifyEvent+int(event.Len)]
+				offset += syscall.SizeofInotifyEvent + int(event.Len)
+
+				if event.Mask&syscall.IN_Q_OVERFLOW != 0 {
+					//directories created meanwhile are not watched yet
+					addWatch(src)
+					changes <- ""
+					continue
+				}
+
+				dir, ok := watches[event.Wd]
+				if !ok {
+					continue
+				}
+
+				name := strings.TrimRight(string(nameBytes), "\x00")
+				file := path.Join(dir, name)
+
+				if event.Mask&syscall.IN_ISDIR != 0 && event.Mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+					addWatch(file)
+				}
+
+				//report the top level entry of src
+				rel := strings.TrimPrefix(file, src+"/")
+				if index := strings.Index(rel, "/"); index >= 0 {
+					rel = rel[:index]
+				}
+				if rel != "" && !strings.HasPrefix(rel, ".") {
+					changes <- rel
+				}
+			}
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+import (
+	"errors"
+)
+
+// startNotify is only implemented with inotify, other systems fall back to polling.
+func startNotify(src string, changes chan<- string) error {
+	return errors.New("not supported on this system")
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/xsm1997/animeLinker/release"
+)
+
+// ParseResult is what the parse command tells about one name.
+type ParseResult struct {
+	Name         string              `json:"name"`
+	Title        string              `json:"title"`
+	Season       int                 `json:"season"`
+	Episode      string              `json:"episode"`
+	Ext          string              `json:"ext"`
+	Language     string              `json:"language,omitempty"`
+	SpecialDir   string              `json:"specialDir,omitempty"`
+	Tokens       []ParseToken        `json:"tokens"`
+	TitleTrace   []release.TraceStep `json:"titleTrace"`
+	EpisodeTrace []release.TraceStep `json:"episodeTrace"`
+}
+
+// ParseToken is one token of the lexer.
+type ParseToken struct {
+	Kind string `json:"kind"`
+	Text string `json:"text"`
+}
+
+var jsonOutput = flag.Bool("json", false, "parse: print the results as json")
+
+// explainName parses name, recording the steps of the title and episode probing.
+func explainName(name string) ParseResult {
+	r := parser.Parse(name)
+
+	result := ParseResult{
+		Name:         name,
+		Title:        r.Title,
+		Season:       r.Season,
+		Episode:      r.Episode,
+		Ext:          parser.LanguageExt(name),
+		Language:     r.Language,
+		SpecialDir:   r.SpecialDir,
+		Tokens:       make([]ParseToken, 0),
+		TitleTrace:   make([]release.TraceStep, 0),
+		EpisodeTrace: make([]release.TraceStep, 0),
+	}
+
+	base, _ := getExtName(name)
+	for _, token := range parser.Tokenize(base) {
+		result.Tokens = append(result.Tokens, ParseToken{Kind: token.Kind.String(), Text: token.Text})
+	}
+
+	parser.Trace = func(step release.TraceStep) {
+		result.TitleTrace = append(result.TitleTrace, step)
+	}
+	parser.ProbeTitle(base)
+
+	parser.Trace = func(step release.TraceStep) {
+		result.EpisodeTrace = append(result.EpisodeTrace, step)
+	}
+	parser.ProbeEpisode(name)
+
+	parser.Trace = nil
+
+	return result
+}
+
+func printTrace(steps []release.TraceStep) {
+	for _, step := range steps {
+		if step.Rule != "" {
+			fmt.Printf("    [%s] `%s`\n", step.Step, step.Rule)
+		} else {
+			fmt.Printf("    [%s]\n", step.Step)
+		}
+		if step.Input != "" {
+			fmt.Printf("        %q => %q\n", step.Input, step.Output)
+		} else {
+			fmt.Printf("        => %q\n", step.Output)
+		}
+		if len(step.Removed) > 0 {
+			fmt.Printf("        removed %q\n", step.Removed)
+		}
+	}
+}
+
+// parseNames prints how names are parsed, for debugging the config rules.
+func parseNames(names []string) {
+	results := make([]ParseResult, len(names))
+	for i, name := range names {
+		results[i] = explainName(name)
+	}
+
+	if *jsonOutput {
+		data, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			fmt.Printf("Cannot encode results: %s.\n", err.Error())
+			os.Exit(1)
+		}
+
+		fmt.Println(string(data))
+		return
+	}
+
+	for _, result := range results {
+		tokens := make([]string, len(result.Tokens))
+		for i, token := range result.Tokens {
+			tokens[i] = fmt.Sprintf("%s:%q", token.Kind, token.Text)
+		}
+
+		fmt.Printf("[NAME] %s\n", result.Name)
+		fmt.Printf("  tokens:  %s\n", strings.Join(tokens, " "))
+		fmt.Printf("  title:   %s\n", result.Title)
+		if result.Season > 0 {
+			fmt.Printf("  season:  %d\n", result.Season)
+		} else {
+			fmt.Println("  season:  not in name")
+		}
+		fmt.Printf("  episode: %s\n", result.Episode)
+		fmt.Printf("  ext:     %s\n", result.Ext)
+		if result.Language != "" {
+			fmt.Printf("  lang:    %s\n", result.Language)
+		}
+		if result.SpecialDir != "" {
+			fmt.Printf("  special: %s\n", result.SpecialDir)
+		}
+		fmt.Println("  title steps:")
+		printTrace(result.TitleTrace)
+		fmt.Println("  episode steps:")
+		printTrace(result.EpisodeTrace)
+		fmt.Println()
+	}
+}
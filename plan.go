@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+)
+
+// LinkEntry is one proposed link, as written by the plan command and carried out by apply.
+type LinkEntry struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Name        string `json:"name"`
+	Episode     string `json:"episode"`
+	Season      string `json:"season"`
+	Skipped     bool   `json:"skipped"`
+	Video       string `json:"video,omitempty"` //source of the video a companion in a subfolder belongs to
+}
+
+var (
+	planMode     bool
+	plannedLinks []LinkEntry
+)
+
+func buildLinkEntries(dir, destDir string, videos, newVideos, newFilenames, episodes []string, companionOf []int, linkWithNewNames bool) []LinkEntry {
+	entries := make([]LinkEntry, len(videos))
+
+	for i, oldName := range videos {
+		entry := LinkEntry{
+			Source:  path.Join(dir, oldName),
+			Episode: episodes[i],
+		}
+		if companionOf[i] >= 0 {
+			entry.Video = path.Join(dir, videos[companionOf[i]])
+		}
+
+		video, _ := getExtName(newVideos[i])
+		if *mode == "anime" {
+			entry.Season, entry.Name = getSplitPath(video)
+		} else {
+			_, entry.Name = getSplitPath(video)
+		}
+
+		if episodes[i] == "" && *mode == "anime" || episodes[i] == "$$$$$" {
+			//omitted video
+			entry.Skipped = true
+			entry.Episode = ""
+		} else if linkWithNewNames {
+			entry.Destination = path.Join(destDir, newFilenames[i])
+		} else {
+			entry.Destination = path.Join(destDir, oldName)
+		}
+
+		entries[i] = entry
+	}
+
+	return entries
+}
+
+func writePlan(file string, entries []LinkEntry) error {
+	if entries == nil {
+		entries = []LinkEntry{}
+	}
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(file, append(data, '\n'), 0666)
+}
+
+func readPlan(file string) ([]LinkEntry, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []LinkEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("parse %s: %s", file, err.Error())
+	}
+
+	for i, entry := range entries {
+		if !entry.Skipped && (entry.Source == "" || entry.Destination == "") {
+			return nil, fmt.Errorf("entry %d of %s has no source or destination", i+1, file)
+		}
+	}
+
+	return entries, nil
+}
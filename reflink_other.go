@@ -0,0 +1,13 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+import (
+	"errors"
+)
+
+// reflinkFile is only implemented with FICLONE on linux.
+func reflinkFile(oldPath, newPath string) error {
+	return errors.New("reflinks not supported on this system")
+}
@@ -0,0 +1,55 @@
+package release
+
+// Confidence scores of the title, season and episode decisions, from 0 to 1.
+const (
+	ConfidenceHigh   = 1.0 //found where it is expected, like "[01]" or " - 01"
+	ConfidenceMedium = 0.7 //likely, like a trailing roman numeral as season
+	ConfidenceLow    = 0.4 //a guess, like a bare number as episode
+	ConfidenceNone   = 0.0 //nothing found
+)
+
+// Confidence returns the lowest confidence of the title, season and episode of r.
+func (r *ParsedRelease) Confidence() float64 {
+	confidence := r.TitleConfidence
+	if r.SeasonConfidence < confidence {
+		confidence = r.SeasonConfidence
+	}
+	if r.EpisodeConfidence < confidence {
+		confidence = r.EpisodeConfidence
+	}
+
+	return confidence
+}
+
+// titleConfidence tells how sure the title probed from base is.
+func (p *Parser) titleConfidence(base, title string) float64 {
+	if title == "" {
+		return ConfidenceNone
+	}
+
+	//the title was not free text, but picked among the bracket groups
+	if findToken(p.Tokenize(base), TokenTitle) != "" {
+		return ConfidenceLow
+	}
+
+	return ConfidenceHigh
+}
+
+// seasonConfidence tells how sure the season found in base is.
+func (p *Parser) seasonConfidence(base string) float64 {
+	if p.Movie {
+		return ConfidenceHigh
+	}
+
+	if season, _ := FindSeason(base); season > 0 {
+		return ConfidenceHigh
+	}
+
+	//roman numerals may be part of the title, like in "Final Fantasy VII"
+	if _, season := p.stripRomanSeason(p.ProbeName(base)); season > 0 {
+		return ConfidenceMedium
+	}
+
+	//most releases without season token are first seasons
+	return ConfidenceHigh
+}
@@ -0,0 +1,196 @@
+package release
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+var (
+	//override tags and line breaks of ass dialogues, like {\fad(200,0)} or \N
+	assOverrideRegex = regexp.MustCompile(`\{[^}]*\}`)
+	assBreakRegex    = regexp.MustCompile(`\\[Nn]`)
+	srtTimeRegex     = regexp.MustCompile(`^\d{1,2}:\d{2}:\d{2}[,.]\d{1,3}\s*-->`)
+	styleWordRegex   = regexp.MustCompile(`[A-Za-z]+|\p{Han}+`)
+
+	//common characters written differently in simplified and traditional chinese
+	simplifiedChars  = "这个们说来时会过还没么为对样发经现后里开问实见间觉让认话进东车长门马书买卖头边从关应点热爱国学电体听"
+	traditionalChars = "這個們說來時會過還沒麼為對樣發經現後裡開問實見間覺讓認話進東車長門馬書買賣頭邊從關應點熱愛國學電體聽"
+)
+
+// DetectLanguage guesses the language of a subtitle from its content, a .ass or .srt file.
+// It counts the dialogue lines written in chinese, japanese and english, and tells
+// simplified from traditional chinese by the characters they write differently.
+// A chinese and japanese subtitle counts as its chinese variant, like "chs&jpn".
+// Short subtitles are told by the languages in their style names and title.
+// It returns "" when it cannot tell.
+func (p *Parser) DetectLanguage(data []byte) string {
+	text, ok := decodeSubtitle(data)
+	if !ok {
+		//no utf-8 or utf-16, so gbk or big5 chinese
+		return detectChineseEncoding(data)
+	}
+
+	var zh, ja, en, simplified, traditional int
+	hints := make(map[string]int)
+
+	for _, line := range p.getDialogues(text, hints) {
+		var han, kana, latin, simplifiedLine, traditionalLine int
+		for _, r := range line {
+			switch {
+			case unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r):
+				kana++
+			case unicode.Is(unicode.Han, r):
+				han++
+				if strings.ContainsRune(simplifiedChars, r) {
+					simplifiedLine++
+				} else if strings.ContainsRune(traditionalChars, r) {
+					traditionalLine++
+				}
+			case r < utf8.RuneSelf && unicode.IsLetter(r):
+				latin++
+			}
+		}
+
+		switch {
+		case kana > 0:
+			ja++
+		case han > 0:
+			//japanese shares many characters, only chinese lines count
+			zh++
+			simplified += simplifiedLine
+			traditional += traditionalLine
+		case latin > 0:
+			en++
+		}
+	}
+
+	total := zh + ja + en
+	if total < 5 {
+		for _, language := range []string{"zh-Hans", "zh-Hant", "zh", "ja", "en"} {
+			if hints[language] > 0 {
+				return language
+			}
+		}
+		return ""
+	}
+
+	//chinese subtitles often keep songs in japanese, bilingual ones are half and half,
+	//and japanese ones have some lines of kanji only
+	switch {
+	case zh*3 >= total && simplified > traditional:
+		return "zh-Hans"
+	case zh*3 >= total && traditional > simplified:
+		return "zh-Hant"
+	case zh*3 >= total:
+		return "zh"
+	case ja >= en:
+		return "ja"
+	}
+
+	return "en"
+}
+
+// decodeSubtitle returns data as text, decoding utf-16 by its byte order mark.
+// ok is false for text in legacy encodings.
+func decodeSubtitle(data []byte) (text string, ok bool) {
+	var order func(b []byte) uint16
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		order = func(b []byte) uint16 { return uint16(b[0]) | uint16(b[1])<<8 }
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		order = func(b []byte) uint16 { return uint16(b[1]) | uint16(b[0])<<8 }
+	}
+
+	if order != nil {
+		units := make([]uint16, 0, len(data)/2)
+		for i := 2; i+1 < len(data); i += 2 {
+			units = append(units, order(data[i:i+2]))
+		}
+		return string(utf16.Decode(units)), true
+	}
+
+	data = bytes.TrimPrefix(data, []byte{0xEF, 0xBB, 0xBF})
+	//the data may end in the middle of a rune
+	if len(data) > utf8.UTFMax {
+		ok = utf8.Valid(data[:len(data)-utf8.UTFMax])
+	} else {
+		ok = utf8.Valid(data)
+	}
+
+	return string(data), ok
+}
+
+// detectChineseEncoding tells gbk from big5 by the second bytes of the characters:
+// big5 uses 0x40-0x7E too, where gbk for common characters uses 0xA1-0xFE only.
+func detectChineseEncoding(data []byte) string {
+	var low, high int
+	for i := 0; i+1 < len(data); i++ {
+		if data[i] < 0x81 {
+			continue
+		}
+
+		if data[i+1] >= 0x40 && data[i+1] <= 0x7E {
+			low++
+		} else if data[i+1] >= 0xA1 {
+			high++
+		}
+		i++
+	}
+
+	switch {
+	case low+high < 10:
+		return ""
+	case low*10 > low+high:
+		return "zh-Hant"
+	}
+
+	return "zh-Hans"
+}
+
+// getDialogues returns the text lines of an ass or srt subtitle, without tags.
+// The languages named by the ass styles and the script title are counted in hints.
+func (p *Parser) getDialogues(text string, hints map[string]int) []string {
+	ass := strings.Contains(text, "[Script Info]") || strings.Contains(text, "[Events]")
+
+	lines := make([]string, 0)
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+
+		if ass {
+			switch {
+			case strings.HasPrefix(line, "Title:") || strings.HasPrefix(line, "Style:"):
+				name := strings.SplitN(strings.TrimSpace(line[6:]), ",", 2)[0]
+				for _, word := range styleWordRegex.FindAllString(name, -1) {
+					if language := p.NormalizeLanguage(word); language != "" {
+						hints[language]++
+					}
+				}
+				continue
+			case !strings.HasPrefix(line, "Dialogue:"):
+				continue
+			}
+
+			//the text is the 10th field, it may contain commas
+			fields := strings.SplitN(line, ",", 10)
+			if len(fields) < 10 {
+				continue
+			}
+			line = assOverrideRegex.ReplaceAllString(fields[9], "")
+		} else if srtTimeRegex.MatchString(line) || strings.Trim(line, "0123456789") == "" {
+			continue
+		}
+
+		//bilingual lines are split by \N
+		for _, part := range assBreakRegex.Split(line, -1) {
+			if strings.TrimSpace(part) != "" {
+				lines = append(lines, part)
+			}
+		}
+	}
+
+	return lines
+}
@@ -0,0 +1,118 @@
+package release
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	diffNumberRegex  = regexp.MustCompile(`\d+(?:\.\d{1,2})?(?:[vV]\d{1,2})?`)
+	diffTrimRegex    = regexp.MustCompile(`[\s\[(【（第#_.\-]+$`)
+	diffEpisodeRegex = regexp.MustCompile(`(?i)([\s\d])ep?$`)
+)
+
+// DiffEpisodes compares the names of the files of one release.
+// The number changing from file to file is the episode, even where the regexes
+// of ProbeEpisode are fooled by years, resolutions or volumes.
+//
+// It returns the episode of every name, "" for names not fitting the others,
+// and the title found in the prefix shared by the names.
+// episodes is nil when no single number changes.
+func (p *Parser) DiffEpisodes(names []string) (episodes []string, title string) {
+	bases := make([]string, len(names))
+	fields := make([][][]int, len(names))
+	counts := make(map[int]int)
+
+	for i, name := range names {
+		base, _ := p.SplitExt(name)
+		//crc changes too, but it is no episode
+		base = crcRegex.ReplaceAllString(base, "")
+
+		bases[i] = base
+		fields[i] = diffNumberRegex.FindAllStringIndex(base, -1)
+		if len(fields[i]) > 0 {
+			counts[len(fields[i])]++
+		}
+	}
+
+	//only names with the usual count of numbers can be aligned
+	count := 0
+	for n, c := range counts {
+		if c > counts[count] || c == counts[count] && n < count {
+			count = n
+		}
+	}
+
+	aligned := make([]int, 0)
+	for i := range names {
+		if len(fields[i]) == count {
+			aligned = append(aligned, i)
+		}
+	}
+
+	if len(aligned) < 2 {
+		return nil, ""
+	}
+
+	best := -1
+	bestCount := 0
+	tie := false
+	for j := 0; j < count; j++ {
+		values := make(map[string]bool)
+		ok := true
+
+		for _, i := range aligned {
+			value := bases[i][fields[i][j][0]:fields[i][j][1]]
+			value = episodeVerRegex.ReplaceAllString(value, "")
+			//no episode has 4 digits, like years or 1080
+			if len(strings.Split(value, ".")[0]) > 3 {
+				ok = false
+				break
+			}
+			values[value] = true
+		}
+
+		if !ok || len(values) < 2 {
+			continue
+		}
+
+		if len(values) > bestCount {
+			best = j
+			bestCount = len(values)
+			tie = false
+		} else if len(values) == bestCount {
+			tie = true
+		}
+	}
+
+	if best < 0 || tie {
+		return nil, ""
+	}
+
+	episodes = make([]string, len(names))
+	prefix := bases[aligned[0]]
+	for _, i := range aligned {
+		start := fields[i][best][0]
+		episodes[i] = bases[i][start:fields[i][best][1]]
+
+		if start < len(prefix) {
+			prefix = prefix[:start]
+		}
+		for k := 0; k < len(prefix); k++ {
+			if bases[i][k] != prefix[k] {
+				prefix = prefix[:k]
+				break
+			}
+		}
+	}
+
+	//cut an unfinished rune and the separators before the episode, like " - " or "S01E"
+	prefix = strings.ToValidUTF8(prefix, "")
+	prefix = diffTrimRegex.ReplaceAllString(prefix, "")
+	prefix = diffEpisodeRegex.ReplaceAllString(prefix, "$1")
+	prefix = diffTrimRegex.ReplaceAllString(prefix, "")
+
+	title = strings.TrimSpace(p.ProbeTitle(prefix))
+
+	return episodes, title
+}
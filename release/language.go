@@ -0,0 +1,133 @@
+package release
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+// LanguageRule maps the subtitle and audio language tags matching Pattern to Code,
+// the ISO 639 code understood by Jellyfin/Emby. Patterns match whole tags ignoring case.
+type LanguageRule struct {
+	Pattern string `yaml:"pattern" json:"pattern"`
+	Code    string `yaml:"code" json:"code"`
+
+	regex *regexp.Regexp
+}
+
+const (
+	//flags kept after the language, like in ".ja.forced.ass"
+	LanguageDefault = "default"
+	LanguageForced  = "forced"
+)
+
+var (
+	//bilingual subtitles like "chs&jpn" count as their chinese variant
+	DefaultLanguageRules = []LanguageRule{
+		{Pattern: `((jp|jpn|ja)[&_+-]?)?(chs|sc|gb|zh-?hans|zh-?cn|简|简体|简中|简体中文|简日|简日双语)([&_+-]?(jp|jpn|ja|日|日语|日文))?`, Code: "zh-Hans"},
+		{Pattern: `((jp|jpn|ja)[&_+-]?)?(cht|tc|big5|zh-?hant|zh-?tw|zh-?hk|繁|繁体|繁體|繁中|繁体中文|繁日|繁日双语)([&_+-]?(jp|jpn|ja|日|日语|日文))?`, Code: "zh-Hant"},
+		{Pattern: `chi|zho|zh|chinese|中文|简繁|简繁日|(chs|sc|gb)[&_+-]?(cht|tc|big5)`, Code: "zh"},
+		{Pattern: `ja|jp|jpn|jap|japanese|日|日语|日文|日本語`, Code: "ja"},
+		{Pattern: `en|eng|english|英|英文|英语`, Code: "en"},
+		{Pattern: `ko|kor|korean|韩|韩语`, Code: "ko"},
+	}
+
+	//extensions of files that may carry a language tag
+	DefaultLanguageExts = []string{
+		".ass", ".ssa", ".srt", ".vtt", ".sup", ".sub", ".idx", ".mka",
+	}
+)
+
+func compileLanguageRule(rule *LanguageRule) (*regexp.Regexp, error) {
+	return regexp.Compile(`(?i)^(?:` + rule.Pattern + `)$`)
+}
+
+// NormalizeLanguage returns the language code of a tag like "chs&jpn", "BIG5" or "简体", or "".
+func (p *Parser) NormalizeLanguage(tag string) string {
+	tag = strings.TrimSpace(tag)
+	if tag == "" {
+		return ""
+	}
+
+	for i := range p.LanguageRules {
+		rule := &p.LanguageRules[i]
+
+		regex := rule.regex
+		if regex == nil {
+			regex, _ = compileLanguageRule(rule)
+		}
+
+		if regex != nil && regex.MatchString(tag) {
+			return rule.Code
+		}
+	}
+
+	return ""
+}
+
+func (p *Parser) isLanguageExt(ext string) bool {
+	for _, languageExt := range p.LanguageExts {
+		if strings.EqualFold(ext, languageExt) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isLanguageFlag(tag string) bool {
+	return strings.EqualFold(tag, LanguageDefault) || strings.EqualFold(tag, LanguageForced)
+}
+
+// LanguageExt returns the extension of name with its language tag as a code,
+// like ".zh-Hans.ass" for "[01].chs&jpn.ass" or ".ja.forced.ass" for "[01].jp.forced.ass".
+// A file without language tag takes the language from a tag of its name, like "[简日双语]".
+// The extension is kept as it is when the language is unknown.
+func (p *Parser) LanguageExt(name string) string {
+	base, ext := p.SplitExt(name)
+
+	r := &ParsedRelease{}
+	r.parseTags(base)
+	language := ""
+	for _, tag := range r.Languages {
+		if language = p.NormalizeLanguage(tag); language != "" {
+			break
+		}
+	}
+
+	return p.WithLanguage(ext, language)
+}
+
+// WithLanguage puts the code of the language tag of ext, or else language, into ext,
+// like ".forced.ass" => ".ja.forced.ass". ext is kept as it is for unknown tags or languages.
+// Files in DefaultLanguage without flags are flagged as default.
+func (p *Parser) WithLanguage(ext, language string) string {
+	lastExt := path.Ext(ext)
+	if !p.isLanguageExt(lastExt) {
+		return ext
+	}
+
+	flags := ""
+	for _, tag := range strings.Split(strings.TrimSuffix(ext, lastExt), ".") {
+		if tag == "" {
+			continue
+		}
+
+		if isLanguageFlag(tag) {
+			flags += "." + strings.ToLower(tag)
+		} else if language = p.NormalizeLanguage(tag); language == "" {
+			//unknown tags are kept
+			return ext
+		}
+	}
+
+	if language == "" {
+		return ext
+	}
+
+	if flags == "" && strings.EqualFold(language, p.DefaultLanguage) {
+		flags = "." + LanguageDefault
+	}
+
+	return "." + language + flags + lastExt
+}
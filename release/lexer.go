@@ -0,0 +1,269 @@
+package release
+
+import (
+	"regexp"
+	"strings"
+)
+
+// TokenKind tells what a token of a name is.
+type TokenKind int
+
+const (
+	TokenText    TokenKind = iota //free text outside brackets, the title and maybe the episode
+	TokenGroup                    //release group, the leading bracket
+	TokenTitle                    //title in brackets, when there is no free text
+	TokenEpisode                  //like [01], [OVA1] or [第01話]
+	TokenKeyword                  //resolution, codecs, source, languages and the like
+	TokenCRC                      //like [ABCD1234]
+	TokenYear                     //like [2020]
+	TokenOther                    //any other text in brackets
+)
+
+var tokenKindNames = []string{"text", "group", "title", "episode", "keyword", "crc", "year", "other"}
+
+func (k TokenKind) String() string {
+	if int(k) < len(tokenKindNames) {
+		return tokenKindNames[k]
+	}
+
+	return "unknown"
+}
+
+// Token is a bracket group or the free text between bracket groups.
+type Token struct {
+	Text     string    //without the brackets
+	Enclosed bool      //found in brackets
+	Kind     TokenKind //set by Tokenize
+}
+
+var (
+	//brackets enclosing tokens, 〈〉 and 「」 are part of titles
+	tokenBrackets = map[rune]rune{
+		'[': ']',
+		'(': ')',
+		'【': '】',
+		'（': '）',
+		'<': '>',
+	}
+
+	//delimiters between the words of a bracket group, like in "x265_flac" or "HEVC-YUV420P10"
+	keywordDelimiterRegex = regexp.MustCompile(`[\s_+\-.,&/]+`)
+
+	//words of bracket groups that are release tags, matched as whole words ignoring case
+	keywordRegex = regexp.MustCompile(`(?i)^(` + strings.Join([]string{
+		//resolution
+		`\d{3,4}[pi]`, `\d{3,4}[x×]\d{3,4}`, `[248]k`,
+		//video
+		`[xh]26[45]`, `h`, `264`, `265`, `hevc`, `avc`, `av1`, `vp9`, `\d{1,2}bits?`,
+		`hi10p?`, `ma10p`, `ma444`, `main10`, `yuv4[24][024]p\d*`,
+		//audio
+		`\d?(flac|aac|ac3|eac3|dts|truehd|opus|alac|mp3|lpcm)(x\d)?`, `hd`, `ma`,
+		//source
+		`bd`, `bdrip`, `bluray`, `blu`, `ray`, `bdmv`, `bdremux`, `remux`, `web`, `dl`, `webrip`,
+		`dvd`, `dvdrip`, `hdtv`, `tv`, `tvrip`, `rip`,
+		//subtitles
+		`gb`, `big5`, `chs`, `cht`, `sc`, `tc`, `jp`, `jpn`, `jpsc`, `jptc`, `eng?`,
+		`简体?`, `繁体?`, `简中`, `繁中`, `简繁`, `简日`, `繁日`, `简日双语`, `繁日双语`, `简繁日`,
+		`内封`, `外挂`, `内嵌`, `双语`, `字幕`, `中文字幕`, `subs?`, `multi`, `dual`, `audio`,
+		//container
+		`mkv`, `mp4`, `avi`, `m2ts`,
+		//batches
+		`\d*fin`, `end`, `batch`, `complete`, `sp`, `v\d`,
+	}, "|") + `)$`)
+
+	//keywords also removed from free text, the others are common words in titles
+	textKeywordRegex = regexp.MustCompile(`(?i)^(\d{3,4}p|[248]k|blu-?ray|bdrip|webrip|[xh]26[45]|hevc|\d{1,2}bit)$`)
+	textWordRegex    = regexp.MustCompile(`[^\s._]+`)
+
+	episodeTokenRegex = regexp.MustCompile(`^((第\d{1,3}(v\d{1,2}|\.\d{1,2})?[话話])|((CM|OVA|#)?\d{1,3}(v\d{1,2}|\.\d{1,2})?))$`)
+	crcTokenRegex     = regexp.MustCompile(`^[0-9A-Fa-f]{8}$`)
+	yearTokenRegex    = regexp.MustCompile(`^(19|20)\d{2}$`)
+)
+
+// splitTokens splits name into bracket groups and the free text between them.
+// An unclosed bracket is part of the free text.
+func splitTokens(name string) []Token {
+	tokens := make([]Token, 0)
+	runes := []rune(name)
+	start := 0
+
+	for i := 0; i < len(runes); i++ {
+		closing, ok := tokenBrackets[runes[i]]
+		if !ok {
+			continue
+		}
+
+		end := -1
+		for j := i + 1; j < len(runes); j++ {
+			if runes[j] == closing {
+				end = j
+				break
+			}
+		}
+		if end < 0 {
+			continue
+		}
+
+		if i > start {
+			tokens = append(tokens, Token{Text: string(runes[start:i])})
+		}
+		tokens = append(tokens, Token{Text: string(runes[i+1 : end]), Enclosed: true})
+
+		i = end
+		start = end + 1
+	}
+
+	if start < len(runes) {
+		tokens = append(tokens, Token{Text: string(runes[start:])})
+	}
+
+	return tokens
+}
+
+// isKeywordText tells if all words of text are release tags or numbers, like "BDRip 1080p HEVC FLAC".
+func isKeywordText(text string) bool {
+	found := false
+	for _, word := range keywordDelimiterRegex.Split(text, -1) {
+		if word == "" {
+			continue
+		}
+
+		if keywordRegex.MatchString(word) {
+			found = true
+		} else if strings.Trim(word, "0123456789") != "" {
+			return false
+		}
+	}
+
+	return found
+}
+
+// classifyToken tells what a bracket group is, before the context is known.
+func classifyToken(text string) TokenKind {
+	text = strings.TrimSpace(text)
+
+	switch {
+	case text == "":
+		return TokenOther
+	case episodeTokenRegex.MatchString(text):
+		return TokenEpisode
+	case yearTokenRegex.MatchString(text):
+		return TokenYear
+	case crcTokenRegex.MatchString(text) && strings.Trim(text, "0123456789") != "":
+		return TokenCRC
+	case isKeywordText(text):
+		return TokenKeyword
+	}
+
+	return TokenOther
+}
+
+// removeTextKeywords removes the keywords from free text, keeping the delimiters around them.
+func removeTextKeywords(text string) string {
+	return textWordRegex.ReplaceAllStringFunc(text, func(word string) string {
+		if textKeywordRegex.MatchString(word) {
+			return ""
+		}
+		return word
+	})
+}
+
+// Tokenize splits name, without extension, into tokens and tells what each of them is.
+//
+// Bracket groups are classified by their content. The title is the free text,
+// or if there is none, the first bracket group of text that is not the group,
+// like in "[Group][Title][01][1080p]".
+func (p *Parser) Tokenize(name string) []Token {
+	tokens := splitTokens(p.deletePatterns(name))
+
+	hasText := false
+	for i := range tokens {
+		if !tokens[i].Enclosed {
+			tokens[i].Kind = TokenText
+			if strings.TrimSpace(p.cleanText(tokens[i].Text)) != "" {
+				hasText = true
+			}
+			continue
+		}
+
+		tokens[i].Kind = classifyToken(tokens[i].Text)
+	}
+
+	candidates := make([]int, 0)
+	for i, token := range tokens {
+		if token.Kind == TokenOther && strings.TrimSpace(token.Text) != "" {
+			candidates = append(candidates, i)
+		}
+	}
+
+	//the leading bracket is the group, unless it is all there is for a title
+	if len(candidates) > 0 && candidates[0] == 0 && (hasText || len(candidates) > 1) {
+		tokens[0].Kind = TokenGroup
+		candidates = candidates[1:]
+	}
+
+	if !hasText && len(candidates) > 0 {
+		tokens[candidates[0]].Kind = TokenTitle
+	}
+
+	return tokens
+}
+
+// cleanText removes the keywords from free text, and turns dots into spaces for movies.
+func (p *Parser) cleanText(text string) string {
+	text = removeTextKeywords(text)
+	if p.Movie {
+		text = strings.ReplaceAll(text, ".", " ")
+	}
+
+	return text
+}
+
+// freeText joins the free text of tokens without keywords.
+func (p *Parser) freeText(tokens []Token) string {
+	var text strings.Builder
+	for _, token := range tokens {
+		if token.Kind == TokenText {
+			text.WriteString(p.cleanText(token.Text))
+		}
+	}
+
+	return text.String()
+}
+
+// traceKeywords traces the keywords removed from the free text of tokens.
+func (p *Parser) traceKeywords(tokens []Token, text string) {
+	if p.Trace == nil {
+		return
+	}
+
+	var input strings.Builder
+	removed := make([]string, 0)
+	for _, token := range tokens {
+		if token.Kind != TokenText {
+			continue
+		}
+
+		input.WriteString(token.Text)
+		for _, word := range textWordRegex.FindAllString(token.Text, -1) {
+			if textKeywordRegex.MatchString(word) {
+				removed = append(removed, word)
+			}
+		}
+	}
+
+	if len(removed) > 0 {
+		p.trace("keyword", textKeywordRegex.String(), input.String(), text, removed)
+	}
+}
+
+// findToken returns the text of the first token of kind, or "".
+func findToken(tokens []Token, kind TokenKind) string {
+	for _, token := range tokens {
+		if token.Kind == kind {
+			return strings.TrimSpace(token.Text)
+		}
+	}
+
+	return ""
+}
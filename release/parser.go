@@ -0,0 +1,283 @@
+// Package release parses the names of anime and movie releases,
+// like "[Group] Title - 01v2 [1080p][ABCD1234].mkv".
+// Other tools import it as github.com/xsm1997/animeLinker/release.
+package release
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+var (
+	//brackets and release tags are handled by the lexer, see Tokenize
+	DefaultDeleteRegex = []string{}
+
+	DefaultDeleteChar = []string{
+		" - ",
+	}
+
+	//subtitle language tags kept as a second extension, like "[02].sc.ass"
+	DefaultSubtitleTags = []string{
+		".sc", ".tc", ".chs", ".cht", ".en", ".jp",
+	}
+)
+
+// Parser holds the tables used to parse names.
+// The zero value is not usable, create parsers with NewParser.
+type Parser struct {
+	DeleteRegex     []string       //patterns deleted from names before they are tokenized
+	DeleteChar      []string       //strings replaced by a space in titles
+	SubtitleTags    []string       //second extensions kept with the extension
+	SpecialRules    []SpecialRule  //checked in order, the first match wins
+	SpecialsDir     string         //directory of OVA, SP and recaps
+	LanguageRules   []LanguageRule //checked in order, the first match wins
+	LanguageExts    []string       //extensions of files that may carry a language tag
+	DefaultLanguage string         //language code flagged as the default track, like "zh-Hans"
+	Movie           bool           //names are movies, dots are spaces and there are no seasons
+
+	Trace func(step TraceStep) //called with the steps of ProbeName and ProbeEpisode, for debugging
+}
+
+// NewParser returns a parser with the default tables.
+func NewParser() *Parser {
+	return &Parser{
+		DeleteRegex:   append([]string{}, DefaultDeleteRegex...),
+		DeleteChar:    append([]string{}, DefaultDeleteChar...),
+		SubtitleTags:  append([]string{}, DefaultSubtitleTags...),
+		SpecialRules:  append([]SpecialRule{}, DefaultSpecialRules...),
+		SpecialsDir:   DefaultSpecialsDir,
+		LanguageRules: append([]LanguageRule{}, DefaultLanguageRules...),
+		LanguageExts:  append([]string{}, DefaultLanguageExts...),
+	}
+}
+
+// Compile checks and compiles the patterns of the parser after its tables are changed.
+func (p *Parser) Compile() error {
+	for _, str := range p.DeleteRegex {
+		if _, err := regexp.Compile(str); err != nil {
+			return fmt.Errorf("bad deleteRegex %s: %s", str, err.Error())
+		}
+	}
+
+	for i := range p.SpecialRules {
+		regex, err := regexp.Compile(p.SpecialRules[i].Pattern)
+		if err != nil {
+			return fmt.Errorf("bad special rule %s: %s", p.SpecialRules[i].Pattern, err.Error())
+		}
+
+		p.SpecialRules[i].regex = regex
+	}
+
+	for i := range p.LanguageRules {
+		regex, err := compileLanguageRule(&p.LanguageRules[i])
+		if err != nil {
+			return fmt.Errorf("bad language rule %s: %s", p.LanguageRules[i].Pattern, err.Error())
+		}
+
+		p.LanguageRules[i].regex = regex
+	}
+
+	return nil
+}
+
+func checkExtName(ext string) bool {
+	if len(ext) > 6 { //long ext names
+		return false
+	}
+
+	ext = ext[1:]
+	for _, c := range ext {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// SplitExt splits name into the name and the extension,
+// which includes a subtitle tag like in "[02].sc.ass".
+func (p *Parser) SplitExt(name string) (string, string) {
+	extname := path.Ext(name)
+
+	if extname == "" {
+		return name, ""
+	}
+
+	if !checkExtName(extname) {
+		return name, ""
+	}
+	name = name[:len(name)-len(extname)]
+
+	//handle "[02].sc.ass", "[02].chs&jpn.forced.ass" etc.
+	//only listed tags count, so configured tags may contain any chars
+	languageExt := p.isLanguageExt(extname)
+	for {
+		extname2 := path.Ext(name)
+		if extname2 == "" {
+			break
+		}
+
+		tag := extname2[1:]
+		if languageExt && isLanguageFlag(tag) {
+			name = name[:len(name)-len(extname2)]
+			extname = extname2 + extname
+			continue
+		}
+
+		f := false
+		for _, match := range p.SubtitleTags {
+			if extname2 == match {
+				f = true
+				break
+			}
+		}
+
+		if f || languageExt && p.NormalizeLanguage(tag) != "" {
+			name = name[:len(name)-len(extname2)]
+			extname = extname2 + extname
+		}
+		break
+	}
+
+	return name, extname
+}
+
+func (p *Parser) deletePatterns(name string) string {
+	for _, str := range p.DeleteRegex {
+		regex := regexp.MustCompile(str)
+		if removed := regex.FindAllString(name, -1); len(removed) > 0 {
+			newName := regex.ReplaceAllString(name, "")
+			p.trace("deleteRegex", str, name, newName, removed)
+			name = newName
+		}
+	}
+
+	return name
+}
+
+// ProbeName returns the name without tags and episode number, keeping the extension.
+func (p *Parser) ProbeName(name string) string {
+	name, ext := p.SplitExt(name)
+
+	tokens := p.Tokenize(name)
+	name = strings.TrimSpace(p.freeText(tokens))
+	p.traceKeywords(tokens, name)
+
+	if name == "" {
+		name = findToken(tokens, TokenTitle)
+		if name == "" {
+			return ""
+		}
+		p.trace("titleToken", "", "", name, nil)
+	}
+
+	//delete chars
+	for _, char := range p.DeleteChar {
+		if count := strings.Count(name, char); count > 0 {
+			newName := strings.ReplaceAll(name, char, " ")
+			removed := make([]string, count)
+			for i := range removed {
+				removed[i] = char
+			}
+			p.trace("deleteChar", char, name, newName, removed)
+			name = newName
+		}
+	}
+
+	//delete EP number
+	regex := regexp.MustCompile(`((\[?(CM|OVA|#)?\d{1,3}(v\d{1,2}|\.\d{1,2})?\]?)|(\[?第\d{1,3}(v\d{1,2}|\.\d{1,2})?[话話]\]?))`)
+	if removed := regex.FindAllString(name, -1); len(removed) > 0 {
+		newName := regex.ReplaceAllString(name, "")
+		p.trace("episodeNumber", regex.String(), name, newName, removed)
+		name = newName
+	}
+	name = strings.TrimSpace(name)
+
+	if p.Movie {
+		ssIndex := strings.Index(name, "  ")
+		if ssIndex > 0 {
+			p.trace("movieCut", "  ", name, name[:ssIndex], []string{name[ssIndex:]})
+			name = name[:ssIndex]
+		}
+	}
+
+	return name + ext
+}
+
+// ProbeEpisode returns the episode as written in name, like "01", "13.5", "02v2" or "OVA1".
+func (p *Parser) ProbeEpisode(name string) string {
+	episode, _ := p.probeEpisode(name)
+	return episode
+}
+
+// probeEpisode returns the episode and how sure it is.
+func (p *Parser) probeEpisode(name string) (string, float64) {
+	name, _ = p.SplitExt(name)
+	tokens := p.Tokenize(name)
+
+	//E05 and S01E05 in the text, not in the group, the tags or the crc, like [7E12ABCD]
+	texts := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		switch token.Kind {
+		case TokenText, TokenTitle, TokenEpisode, TokenOther:
+			texts = append(texts, token.Text)
+		}
+	}
+
+	exxRegex := regexp.MustCompile(`[Ee][Pp]?\d{1,3}`)
+	exxStr := exxRegex.FindString(strings.Join(texts, " "))
+	if exxStr != "" {
+		exxStr = regexp.MustCompile("([EePp ]|-)").ReplaceAllString(exxStr, "")
+		p.trace("episode", exxRegex.String(), name, exxStr, nil)
+		return exxStr, ConfidenceHigh
+	}
+
+	numbers := ""
+	confidence := ConfidenceHigh
+
+	//detect [01], [OVA1], [第01話], etc.
+	for _, token := range tokens {
+		if token.Kind == TokenEpisode {
+			numbers = regexp.MustCompile(`[第话話#]`).ReplaceAllString(token.Text, "")
+		}
+	}
+	if numbers != "" {
+		p.trace("episode", "episode token", name, numbers, nil)
+	}
+
+	name = strings.TrimSpace(p.freeText(tokens))
+
+	if numbers == "" {
+		//detect - 01, -12.5, etc.
+		regex := regexp.MustCompile(`\s*-\s*((第\d{1,3}(v\d{1,2}|\.\d{1,2})?[话話])|((CM|OVA|#)?\d{1,3}(v\d{1,2}|\.\d{1,2})?))`)
+		numbersSlice := regex.FindAllString(name, -1)
+		if len(numbersSlice) > 0 {
+			numbers = numbersSlice[len(numbersSlice)-1]
+			numbers = regexp.MustCompile(`[-第话話#]`).ReplaceAllString(numbers, "")
+			numbers = strings.TrimSpace(numbers)
+			p.trace("episode", regex.String(), name, numbers, nil)
+		}
+	}
+
+	if numbers == "" {
+		//any number after a space, like "Show 01", but also "Show vol1 2021"
+		confidence = ConfidenceLow
+		regex := regexp.MustCompile(`\s+((第\d{1,3}(v\d{1,2}|\.\d{1,2})?[话話])|((CM|OVA|#)?\d{1,3}(v\d{1,2}|\.\d{1,2})?))`)
+		numbersSlice := regex.FindAllString(name, -1)
+		if len(numbersSlice) > 0 {
+			numbers = numbersSlice[len(numbersSlice)-1]
+			numbers = regexp.MustCompile(`[第话話#]`).ReplaceAllString(numbers, "")
+			numbers = strings.TrimSpace(numbers)
+			p.trace("episode", regex.String(), name, numbers, nil)
+		}
+	}
+
+	if numbers == "" {
+		confidence = ConfidenceNone
+	}
+
+	return numbers, confidence
+}
@@ -0,0 +1,179 @@
+package release
+
+import (
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ParsedRelease is everything found in the name of a release file or directory.
+// Fields not found in the name are left empty.
+type ParsedRelease struct {
+	Name       string   //the parsed name
+	Title      string   //title without season token
+	AltTitles  []string //other titles, like in "进击的巨人 / Shingeki no Kyojin"
+	Season     int
+	Episode    string   //like "01" or "13.5", without version
+	Episodes   []string //all episodes of a multi-episode file like "S01E01-E02"
+	Version    int      //2 for "01v2"
+	Group      string
+	Resolution string //like "1080p"
+	VideoCodec string //AVC, HEVC, AV1 or VP9
+	AudioCodec string //like FLAC or AAC
+	Source     string //like BDRip or WEB-DL
+	CRC32      string //upper case hex
+	Year       int
+	Languages  []string //subtitle language tags, like "sc" or "GB"
+	Language   string   //ISO 639 code of the first known language tag, like "zh-Hans"
+	Ext        string   //extension, with the subtitle tag
+	Special    string   //special or extra token, like "NCOP1" or "OVA1"
+	SpecialDir string   //specials directory or extras folder of Special
+
+	TitleConfidence   float64 //how sure the title is, see ConfidenceHigh
+	SeasonConfidence  float64
+	EpisodeConfidence float64
+}
+
+var (
+	//separators around release tags, \b does not work with names like "x265_flac"
+	tagStart = `(?:^|[\s\[\](){}【】（）_.+,-])`
+	tagEnd   = `(?:$|[\s\[\](){}【】（）_.+,-])`
+
+	yearRegex       = regexp.MustCompile(tagStart + `((?:19|20)\d{2})` + tagEnd)
+	resolutionRegex = regexp.MustCompile(`(?i)` + tagStart + `(\d{3,4}[pi]|4k|\d{3,4}x(\d{3,4}))` + tagEnd)
+	sourceRegex     = regexp.MustCompile(`(?i)` + tagStart + `(BDRip|BluRay|Blu-ray|BDMV|BD|WEB-DL|WEBRip|WEB|DVDRip|DVD|HDTV|TVRip)` + tagEnd)
+	videoCodecRegex = regexp.MustCompile(`(?i)` + tagStart + `(x264|x265|H\.?264|H\.?265|HEVC|AVC|AV1|VP9)` + tagEnd)
+	audioCodecRegex = regexp.MustCompile(`(?i)` + tagStart + `\d?(FLAC|AAC|AC3|E-?AC3|DTS(?:-HD)?|TrueHD|Opus|ALAC|MP3|LPCM)(?:x\d)?` + tagEnd)
+	crcRegex        = regexp.MustCompile(`[\[(]([0-9A-Fa-f]{8})[\])]`)
+	versionRegex    = regexp.MustCompile(`\d{1,3}[vV](\d{1,2})` + tagEnd)
+	languageRegex   = regexp.MustCompile(`(?i)` + tagStart + `(GB|BIG5|CHS|CHT|SC|TC|JPSC|JPTC|简体|繁体|简中|繁中|简日|繁日|简繁|简日双语|繁日双语|简繁日)` + tagEnd)
+	multiEpRegex    = regexp.MustCompile(`[Ee][Pp]?(\d{1,3})\s*-\s*[Ee]?[Pp]?(\d{1,3})`)
+	altTitleRegex   = regexp.MustCompile(`\s+[/|]\s+`)
+	episodeVerRegex = regexp.MustCompile(`[vV](\d{1,2})$`)
+
+	sourceNames = map[string]string{
+		"bdrip": "BDRip", "bluray": "BluRay", "blu-ray": "BluRay", "bdmv": "BDMV", "bd": "BD",
+		"web-dl": "WEB-DL", "webrip": "WEBRip", "web": "WEB",
+		"dvdrip": "DVDRip", "dvd": "DVD", "hdtv": "HDTV", "tvrip": "TVRip",
+	}
+
+	videoCodecNames = map[string]string{
+		"x264": "AVC", "h264": "AVC", "h.264": "AVC", "avc": "AVC",
+		"x265": "HEVC", "h265": "HEVC", "h.265": "HEVC", "hevc": "HEVC",
+		"av1": "AV1", "vp9": "VP9",
+	}
+)
+
+// parseTags picks the release tags out of a name without extension.
+func (r *ParsedRelease) parseTags(name string) {
+	if match := yearRegex.FindStringSubmatch(name); match != nil {
+		r.Year, _ = strconv.Atoi(match[1])
+	}
+
+	if match := resolutionRegex.FindStringSubmatch(name); match != nil {
+		resolution := strings.ToLower(match[1])
+		if match[2] != "" {
+			resolution = match[2] + "p"
+		} else if resolution == "4k" {
+			resolution = "2160p"
+		}
+		r.Resolution = resolution
+	}
+
+	if match := sourceRegex.FindStringSubmatch(name); match != nil {
+		r.Source = sourceNames[strings.ToLower(match[1])]
+	}
+
+	if match := videoCodecRegex.FindStringSubmatch(name); match != nil {
+		r.VideoCodec = videoCodecNames[strings.ToLower(match[1])]
+	}
+
+	if match := audioCodecRegex.FindStringSubmatch(name); match != nil {
+		r.AudioCodec = strings.ToUpper(match[1])
+	}
+
+	for _, match := range crcRegex.FindAllStringSubmatch(name, -1) {
+		//all digits is more likely a date
+		if strings.Trim(match[1], "0123456789") != "" {
+			r.CRC32 = strings.ToUpper(match[1])
+		}
+	}
+
+	if match := versionRegex.FindStringSubmatch(name); match != nil {
+		r.Version, _ = strconv.Atoi(match[1])
+	}
+
+	for _, match := range languageRegex.FindAllStringSubmatch(name, -1) {
+		r.Languages = append(r.Languages, match[1])
+	}
+}
+
+// Parse parses the name of a release file or directory.
+func (p *Parser) Parse(name string) *ParsedRelease {
+	r := &ParsedRelease{Name: name}
+
+	base, ext := p.SplitExt(name)
+	r.Ext = ext
+	if tag := strings.TrimSuffix(ext, path.Ext(ext)); tag != "" {
+		r.Languages = append(r.Languages, strings.TrimPrefix(tag, "."))
+	}
+
+	r.parseTags(base)
+	r.Group = findToken(p.Tokenize(base), TokenGroup)
+	for _, tag := range r.Languages {
+		if r.Language = p.NormalizeLanguage(tag); r.Language != "" {
+			break
+		}
+	}
+
+	//title
+	title := p.ProbeTitle(base)
+	titles := altTitleRegex.Split(title, -1)
+	r.Title = strings.TrimSpace(titles[0])
+	for _, alt := range titles[1:] {
+		if alt = strings.TrimSpace(alt); alt != "" {
+			r.AltTitles = append(r.AltTitles, alt)
+		}
+	}
+
+	r.TitleConfidence = p.titleConfidence(base, r.Title)
+
+	if !p.Movie {
+		r.Season = p.Season(base)
+	}
+	r.SeasonConfidence = p.seasonConfidence(base)
+
+	//episode
+	episode, confidence := p.probeEpisode(name)
+	r.EpisodeConfidence = confidence
+	if match := episodeVerRegex.FindStringSubmatch(episode); match != nil {
+		episode = episode[:len(episode)-len(match[0])]
+		r.Version, _ = strconv.Atoi(match[1])
+	}
+
+	r.SpecialDir, r.Special, episode = p.ClassifySpecial(name, episode)
+	if r.SpecialDir != "" && p.IsExtraDir(r.SpecialDir) {
+		//extras have no episode
+		episode = ""
+		r.EpisodeConfidence = ConfidenceHigh
+	}
+	r.Episode = episode
+
+	if match := multiEpRegex.FindStringSubmatch(base); match != nil {
+		first, _ := strconv.Atoi(match[1])
+		last, _ := strconv.Atoi(match[2])
+		for n := first; n <= last && n-first < 100; n++ {
+			number := strconv.Itoa(n)
+			if len(number) < len(match[1]) {
+				number = strings.Repeat("0", len(match[1])-len(number)) + number
+			}
+			r.Episodes = append(r.Episodes, number)
+		}
+	}
+	if len(r.Episodes) == 0 && episode != "" {
+		r.Episodes = []string{episode}
+	}
+
+	return r
+}
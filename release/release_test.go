@@ -0,0 +1,291 @@
+package release
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	in := []string{
+		`[VCB-Studio] Koutetsujou no Kabaneri [03v2][Ma10p_1080p][x265_flac][ABCD1234].sc.ass`,
+		`[Grp] 进击的巨人 / Shingeki no Kyojin S03E05 [WEB-DL 1080p AVC AAC][CHS].mp4`,
+		`[2020][Dungeon ni Deai o Motomeru no wa Machigatte Iru Darouka III][BDRIP][1080P][1-12Fin+SP]`,
+		`[VCB-Studio] Show [NCOP1][Ma10p_1080p][x265_flac].mkv`,
+		`Show S01E01-E03 (BD 1920x1080 HEVC FLACx2).mkv`,
+	}
+
+	out1 := []ParsedRelease{
+		{
+			Title: "Koutetsujou no Kabaneri", Episode: "03", Episodes: []string{"03"}, Version: 2,
+			Group: "VCB-Studio", Resolution: "1080p", VideoCodec: "HEVC", AudioCodec: "FLAC",
+			CRC32: "ABCD1234", Languages: []string{"sc"}, Language: "zh-Hans", Ext: ".sc.ass",
+			TitleConfidence: 1, SeasonConfidence: 1, EpisodeConfidence: 1,
+		},
+		{
+			Title: "进击的巨人", AltTitles: []string{"Shingeki no Kyojin"}, Season: 3, Episode: "05", Episodes: []string{"05"},
+			Group: "Grp", Resolution: "1080p", VideoCodec: "AVC", AudioCodec: "AAC", Source: "WEB-DL",
+			Languages: []string{"CHS"}, Language: "zh-Hans", Ext: ".mp4",
+			TitleConfidence: 1, SeasonConfidence: 1, EpisodeConfidence: 1,
+		},
+		{
+			Title: "Dungeon ni Deai o Motomeru no wa Machigatte Iru Darouka", Season: 3,
+			Resolution: "1080p", Source: "BDRip", Year: 2020,
+			TitleConfidence: 0.4, SeasonConfidence: 0.7, EpisodeConfidence: 0,
+		},
+		{
+			Title: "Show", Group: "VCB-Studio", Resolution: "1080p", VideoCodec: "HEVC", AudioCodec: "FLAC",
+			Ext: ".mkv", Special: "NCOP1", SpecialDir: "extras",
+			TitleConfidence: 1, SeasonConfidence: 1, EpisodeConfidence: 1,
+		},
+		{
+			Title: "Show", Season: 1, Episode: "01", Episodes: []string{"01", "02", "03"},
+			Resolution: "1080p", VideoCodec: "HEVC", AudioCodec: "FLAC", Source: "BD", Ext: ".mkv",
+			TitleConfidence: 1, SeasonConfidence: 1, EpisodeConfidence: 1,
+		},
+	}
+
+	p := NewParser()
+
+	for i, data := range in {
+		o1 := out1[i]
+		o1.Name = data
+
+		r1 := p.Parse(data)
+
+		if !reflect.DeepEqual(*r1, o1) {
+			t.Errorf("Data %s: excepted %+v, got %+v", data, o1, *r1)
+		}
+	}
+}
+
+func TestClassifySpecial(t *testing.T) {
+	in := []string{
+		`[VCB-Studio] Show [NCOP1][Ma10p_1080p][x265_flac].mkv`,
+		`[VCB-Studio] Show [NCED_EP12][Ma10p_1080p][x265_flac].mkv`,
+		`[ANK-Raws] 血界戦線 CM01 (BDrip 1920x1080 HEVC-YUV420P10 FLAC).mkv`,
+		`[VCB-Studio] Show [PV02][Ma10p_1080p][x265_flac].mkv`,
+		`[VCB-Studio] Show [Menu01][Ma10p_1080p][x265_flac].mkv`,
+		`世界最高の暗殺者、異世界貴族に転生する メニュー動画 vol1 (BD 1920x1080 x265 ALAC).mp4`,
+		`[VCB-Studio] Show [OVA1][Ma10p_1080p][x265_flac].mkv`,
+		`[Beatrice-Raws] Show - 10.5 [BDRip 1920x1080 HEVC FLAC].mkv`,
+		`[Snow-Raws] BANANA FISH [01].mkv`,
+		`[Grp] SPY×FAMILY - 03 [1080p].mkv`,
+	}
+
+	out1 := []string{
+		`extras`,
+		`extras`,
+		`trailers`,
+		`trailers`,
+		`extras`,
+		`extras`,
+		`Specials`,
+		`Specials`,
+		``,
+		``,
+	}
+
+	out2 := []string{
+		`NCOP1`,
+		`NCED`,
+		`CM01`,
+		`PV02`,
+		`Menu01`,
+		`メニュー`,
+		`1`,
+		`10.5`,
+		`01`,
+		`03`,
+	}
+
+	p := NewParser()
+
+	for i, data := range in {
+		r1, _, r2 := p.ClassifySpecial(data, p.ProbeEpisode(data))
+
+		if r1 != out1[i] || r2 != out2[i] {
+			t.Errorf("Data %s: excepted %s %s, got %s %s", data, out1[i], out2[i], r1, r2)
+		}
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	in := []string{
+		`[Grp][Title][01][1080p]`,
+		`[Snow-Raws] 牙狼〈GARO〉-VANISING LINE- [01][BDRip 1920x1080 HEVC FLAC]`,
+		`[2020][Dungeon ni Deai o Motomeru no wa Machigatte Iru Darouka III][BDRIP][1080P][1-12Fin+SP]`,
+		`[DanMachi S3][02][BDRIP][1080P][H264_FLAC]`,
+		`[EMD]Arslan Senki[GB_BIG5][X264_AAC][1280X720][7BAA2B61]`,
+	}
+
+	out1 := []string{
+		`group:Grp title:Title episode:01 keyword:1080p`,
+		`group:Snow-Raws text: 牙狼〈GARO〉-VANISING LINE-  episode:01 keyword:BDRip 1920x1080 HEVC FLAC`,
+		`year:2020 title:Dungeon ni Deai o Motomeru no wa Machigatte Iru Darouka III keyword:BDRIP keyword:1080P keyword:1-12Fin+SP`,
+		`title:DanMachi S3 episode:02 keyword:BDRIP keyword:1080P keyword:H264_FLAC`,
+		`group:EMD text:Arslan Senki keyword:GB_BIG5 keyword:X264_AAC keyword:1280X720 crc:7BAA2B61`,
+	}
+
+	p := NewParser()
+
+	for i, data := range in {
+		o1 := out1[i]
+
+		tokens := make([]string, 0)
+		for _, token := range p.Tokenize(data) {
+			tokens = append(tokens, token.Kind.String()+":"+token.Text)
+		}
+		r1 := strings.Join(tokens, " ")
+
+		if r1 != o1 {
+			t.Errorf("Data %s: excepted %s, got %s", data, o1, r1)
+		}
+	}
+}
+
+func TestDiffEpisodes(t *testing.T) {
+	in := [][]string{
+		{`2021 abaaba 2022 [12].mp4`, `2021 abaaba 2022 [13].mp4`, `2021 abaaba 2022 [14].mp4`},
+		{`[Grp] Show - 01 [1080p][ABCD1234].mkv`, `[Grp] Show - 02 [1080p][1234ABCD].mkv`, `[Grp] Show - 03v2 [1080p][DCBA4321].mkv`},
+		{`Show S02E01 1080p.mkv`, `Show S02E02 1080p.mkv`, `Show NCOP.mkv`},
+		{`[Grp] Show [01].mkv`},
+	}
+
+	out1 := []string{
+		`12,13,14 abaaba`,
+		`01,02,03v2 Show`,
+		`01,02, Show`,
+		` `,
+	}
+
+	p := NewParser()
+
+	for i, data := range in {
+		o1 := out1[i]
+
+		episodes, title := p.DiffEpisodes(data)
+		r1 := strings.Join(episodes, ",") + " " + title
+
+		if r1 != o1 {
+			t.Errorf("Data %s: excepted %s, got %s", strings.Join(data, ", "), o1, r1)
+		}
+	}
+}
+
+func TestTrace(t *testing.T) {
+	in := []string{
+		`[Grp] A - B 1080p - 01.mkv`,
+		`[Grp][Title][02][1080p].mkv`,
+	}
+
+	out1 := []string{
+		`keyword deleteChar episodeNumber episode`,
+		`titleToken episode`,
+	}
+
+	p := NewParser()
+
+	for i, data := range in {
+		o1 := out1[i]
+
+		steps := make([]string, 0)
+		p.Trace = func(step TraceStep) {
+			steps = append(steps, step.Step)
+		}
+		p.ProbeName(data)
+		p.ProbeEpisode(data)
+		r1 := strings.Join(steps, " ")
+
+		if r1 != o1 {
+			t.Errorf("Data %s: excepted %s, got %s", data, o1, r1)
+		}
+	}
+}
+
+func TestLanguageExt(t *testing.T) {
+	in := []string{
+		`[Grp] Show [01].chs&jpn.ass`,
+		`[Grp] Show [01].zh-Hans.ass`,
+		`[Grp] Show [01].简体.ass`,
+		`[Grp] Show [01].GB.ass`,
+		`[Grp] Show [01].BIG5.srt`,
+		`[Grp] Show [01].TC_JP.ass`,
+		`[Grp] Show [01].jpn.forced.ass`,
+		`[Grp] Show [01][简日双语].ass`,
+		`[Grp] Show [01].sc.ass`,
+		`[Grp] Show [01].eng.mka`,
+		`[Grp] Show [01].xyz.ass`,
+		`[Grp] Show [01][简日双语].mp4`,
+	}
+
+	out1 := []string{
+		`.zh-Hans.ass`,
+		`.zh-Hans.ass`,
+		`.zh-Hans.ass`,
+		`.zh-Hans.ass`,
+		`.zh-Hant.srt`,
+		`.zh-Hant.ass`,
+		`.ja.forced.ass`,
+		`.zh-Hans.ass`,
+		`.zh-Hans.ass`,
+		`.en.mka`,
+		`.ass`,
+		`.mp4`,
+	}
+
+	p := NewParser()
+
+	for i, data := range in {
+		o1 := out1[i]
+		r1 := p.LanguageExt(data)
+
+		if r1 != o1 {
+			t.Errorf("Data %s: excepted %s, got %s", data, o1, r1)
+		}
+	}
+}
+
+func TestDetectLanguage(t *testing.T) {
+	assHeader := "[Script Info]\nTitle: Show\nScriptType: v4.00+\n\n[V4+ Styles]\nStyle: Default,Arial,20\n\n[Events]\n"
+	assLines := func(lines ...string) string {
+		text := assHeader
+		for _, line := range lines {
+			text += "Dialogue: 0,0:00:01.00,0:00:02.00,Default,,0,0,0,," + line + "\n"
+		}
+		return text
+	}
+
+	in := []string{
+		assLines(`这是什么`, `我们走吧`, `{\fad(200,0)}你说得对`, `没关系`, `时间到了`),
+		assLines(`這是什麼`, `我們走吧`, `你說得對`, `沒關係`, `時間到了`),
+		assLines(`这是什么\Nこれは何`, `我们走吧\N行こう`, `你说得对\Nその通りだ`, `没关系\N大丈夫`, `时间到了\N時間だ`),
+		assLines(`これは何`, `行こう`, `その通りだ`, `大丈夫`, `時間だよ`),
+		"1\n00:00:01,000 --> 00:00:02,000\nWhat is this\n\n2\n00:00:03,000 --> 00:00:04,000\nLet's go\n\n3\n00:00:05,000 --> 00:00:06,000\nYou're right\n\n4\n00:00:07,000 --> 00:00:08,000\nNever mind\n\n5\n00:00:09,000 --> 00:00:10,000\nTime is up\n",
+		"[Script Info]\nTitle: Show\n\n[V4+ Styles]\nStyle: Default-JP,Arial,20\n\n[Events]\n",
+		"\xef\xbb\xbf" + assLines(`这是什么`, `我们走吧`, `你说得对`, `没关系`, `时间到了`),
+		assLines(`OK`),
+	}
+
+	out1 := []string{
+		`zh-Hans`,
+		`zh-Hant`,
+		`zh-Hans`,
+		`ja`,
+		`en`,
+		`ja`,
+		`zh-Hans`,
+		``,
+	}
+
+	p := NewParser()
+
+	for i, data := range in {
+		o1 := out1[i]
+		r1 := p.DetectLanguage([]byte(data))
+
+		if r1 != o1 {
+			t.Errorf("Data %d: excepted %s, got %s", i, o1, r1)
+		}
+	}
+}
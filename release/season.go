@@ -0,0 +1,118 @@
+package release
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	seasonRegex = []*regexp.Regexp{
+		regexp.MustCompile(`(?i)\bseason\s*(\d{1,2})\b`),                                          //Season 2
+		regexp.MustCompile(`(?i)\b(\d{1,2})(?:st|nd|rd|th)\s+season\b`),                           //2nd Season
+		regexp.MustCompile(`\b[Ss](\d{1,2})(?:[Ee][Pp]?\d{1,3}(?:\s*-\s*[Ee]?[Pp]?\d{1,3})?)?\b`), //S2, S02, S02E03, S02E03-E04
+		regexp.MustCompile(`第\s*(\d{1,2}|[一二三四五六七八九十]{1,3})\s*[季期]`),                              //第2期, 第二季
+	}
+
+	romanSeasonRegex = regexp.MustCompile(`\s+(II|III|IV|V|VI|VII|VIII|IX)$`) //Darouka III
+
+	romanNumbers = map[string]int{
+		"II": 2, "III": 3, "IV": 4, "V": 5, "VI": 6, "VII": 7, "VIII": 8, "IX": 9,
+	}
+)
+
+// parseChineseNumber parses numbers up to 99 written like 二, 十二 or 二十.
+func parseChineseNumber(str string) int {
+	digits := map[rune]int{
+		'一': 1, '二': 2, '三': 3, '四': 4, '五': 5, '六': 6, '七': 7, '八': 8, '九': 9,
+	}
+
+	number := 0
+	current := 0
+	for _, r := range str {
+		if r == '十' {
+			if current == 0 {
+				current = 1
+			}
+			number += current * 10
+			current = 0
+		} else {
+			current = digits[r]
+		}
+	}
+
+	return number + current
+}
+
+// FindSeason looks for an explicit season token in name.
+// It returns the season number and the token, or 0 if there is none.
+func FindSeason(name string) (int, string) {
+	for _, regex := range seasonRegex {
+		match := regex.FindStringSubmatch(name)
+		if match == nil {
+			continue
+		}
+
+		season, err := strconv.Atoi(match[1])
+		if err != nil {
+			season = parseChineseNumber(match[1])
+		}
+
+		if season > 0 {
+			return season, match[0]
+		}
+	}
+
+	return 0, ""
+}
+
+// stripRomanSeason takes a trailing roman numeral off a probed title.
+func (p *Parser) stripRomanSeason(title string) (string, int) {
+	title, ext := p.SplitExt(title)
+
+	match := romanSeasonRegex.FindStringSubmatch(title)
+	if match == nil {
+		return title + ext, 0
+	}
+
+	return strings.TrimSpace(title[:len(title)-len(match[0])]) + ext, romanNumbers[match[1]]
+}
+
+// ProbeTitle works like ProbeName, but takes the season token out of the title,
+// so all seasons of a show end up with the same name.
+func (p *Parser) ProbeTitle(name string) string {
+	if p.Movie {
+		return p.ProbeName(name)
+	}
+
+	title := ""
+	if _, token := FindSeason(name); token != "" {
+		title = p.ProbeName(strings.Replace(name, token, " ", 1))
+		title = strings.TrimRight(title, " -")
+	}
+	if title == "" {
+		title = p.ProbeName(name)
+	}
+
+	title, _ = p.stripRomanSeason(title)
+	return title
+}
+
+// Season returns the season number found in names, or 0.
+// The names are searched in order, usually the file name, the release directory and the parent directory.
+func (p *Parser) Season(names ...string) int {
+	for _, name := range names {
+		name, _ = p.SplitExt(name)
+
+		season, _ := FindSeason(name)
+		if season == 0 {
+			_, season = p.stripRomanSeason(p.ProbeName(name))
+		}
+
+		if season > 0 {
+			return season
+		}
+	}
+
+	return 0
+}
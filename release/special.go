@@ -0,0 +1,110 @@
+package release
+
+import (
+	"regexp"
+	"strings"
+)
+
+const (
+	DefaultSpecialsDir = "Specials"
+)
+
+// SpecialRule routes files whose name matches Pattern into Dir.
+// Dir is the specials season directory, or one of the extras folders of Jellyfin/Emby.
+type SpecialRule struct {
+	Pattern string `yaml:"pattern" json:"pattern"`
+	Dir     string `yaml:"dir" json:"dir"`
+
+	regex *regexp.Regexp
+}
+
+var (
+	DefaultSpecialRules = []SpecialRule{
+		{Pattern: `(?i)\bNC[ _-]?(OP|ED)\d{0,2}`, Dir: "extras"},
+		{Pattern: `\b(OP|ED)[ _-]?\d{0,2}\b`, Dir: "extras"},
+		{Pattern: `(?i)\b(Menu)[ _-]?\d{0,2}\b|メニュー`, Dir: "extras"},
+		{Pattern: `(?i)\b(PV|CM|SPOT|Preview|Trailer|Teaser)[ _-]?\d{0,2}\b|予告`, Dir: "trailers"},
+		{Pattern: `(?i)\b(Making|Interview)[ _-]?\d{0,2}\b`, Dir: "featurettes"},
+		{Pattern: `(?i)\b(OVA|OAD)[ _-]?\d{0,2}\b|\b(SP|Special)[ _-]?\d{1,2}\b|\[SP\]`, Dir: DefaultSpecialsDir},
+	}
+
+	decimalEpisodeRegex = regexp.MustCompile(`^\d{1,3}\.\d{1,2}$`)
+	specialNumberRegex  = regexp.MustCompile(`\d{1,3}(\.\d{1,2})?$`)
+)
+
+func (p *Parser) getRuleDir(rule *SpecialRule) string {
+	if rule.Dir == DefaultSpecialsDir {
+		return p.SpecialsDir
+	}
+
+	return rule.Dir
+}
+
+// ClassifySpecial returns the directory of a special or an extra, or "" for regular episodes,
+// with the special token, like "NCOP1", and the episode to name it by.
+// Extras are named by their token, because they usually have no episode number.
+func (p *Parser) ClassifySpecial(name, episode string) (dir, token, newEpisode string) {
+	name, _ = p.SplitExt(name)
+
+	for i := range p.SpecialRules {
+		rule := &p.SpecialRules[i]
+
+		regex := rule.regex
+		if regex == nil {
+			regex = regexp.MustCompile(rule.Pattern)
+		}
+
+		token := regex.FindString(name)
+		if token == "" {
+			continue
+		}
+
+		token = strings.NewReplacer(" ", "", "_", "", "-", "", "[", "", "]", "").Replace(token)
+
+		dir := p.getRuleDir(rule)
+		if dir != p.SpecialsDir {
+			return dir, token, token
+		}
+
+		//specials keep just the number, like OVA1 => 1
+		if episode == "" {
+			episode = token
+		}
+		if number := specialNumberRegex.FindString(episode); number != "" {
+			episode = number
+		}
+
+		return dir, token, episode
+	}
+
+	//recaps like 13.5
+	if decimalEpisodeRegex.MatchString(episode) {
+		return p.SpecialsDir, "", episode
+	}
+
+	return "", "", episode
+}
+
+// IsSpecialDir tells if dir is where ClassifySpecial puts files.
+func (p *Parser) IsSpecialDir(dir string) bool {
+	if dir == "" {
+		return false
+	}
+
+	if dir == p.SpecialsDir {
+		return true
+	}
+
+	for i := range p.SpecialRules {
+		if p.getRuleDir(&p.SpecialRules[i]) == dir {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsExtraDir tells if dir is one of the extras folders, which hold no episodes.
+func (p *Parser) IsExtraDir(dir string) bool {
+	return dir != p.SpecialsDir && p.IsSpecialDir(dir)
+}
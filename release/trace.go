@@ -0,0 +1,25 @@
+package release
+
+// TraceStep is one step of parsing a name that changed or found something.
+// Steps are passed to Parser.Trace when it is set.
+type TraceStep struct {
+	Step    string   `json:"step"`              //deleteRegex, keyword, titleToken, deleteChar, episodeNumber, movieCut or episode
+	Rule    string   `json:"rule"`              //the regex or string applied
+	Input   string   `json:"input"`             //the name before the step
+	Output  string   `json:"output"`            //the name after the step, or the episode found
+	Removed []string `json:"removed,omitempty"` //what the step took out of the name
+}
+
+func (p *Parser) trace(step, rule, input, output string, removed []string) {
+	if p.Trace == nil {
+		return
+	}
+
+	p.Trace(TraceStep{
+		Step:    step,
+		Rule:    rule,
+		Input:   input,
+		Output:  output,
+		Removed: removed,
+	})
+}
@@ -0,0 +1,18 @@
+package main
+
+// isSpecialDir tells if dir is the specials directory or an extras folder.
+func isSpecialDir(dir string) bool {
+	return parser.IsSpecialDir(dir)
+}
+
+// getProbedSeason returns the season directory probed for the regular episodes of newVideos.
+func getProbedSeason(newVideos []string) string {
+	for _, video := range newVideos {
+		dir, _ := getSplitPath(video)
+		if dir != "" && !isSpecialDir(dir) {
+			return dir
+		}
+	}
+
+	return "S01"
+}
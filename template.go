@@ -0,0 +1,216 @@
+package main
+
+import (
+	"flag"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/xsm1997/animeLinker/release"
+)
+
+// Naming rules are templates of placeholders like {title} or {episode:2}.
+// The number after the colon zero-pads numbers to that width.
+// A part enclosed in <> is dropped when any placeholder in it is empty,
+// so "{title}< ({year})>" gives "Show (2020)", or just "Show" when the year is unknown.
+// A rule may contain "/" to create directories.
+//
+// Placeholders: title, season, episode, year, group, resolution, source, codec,
+// crc, version, lang, ext and dir, the probed season or extras directory.
+// The old $name and $episode still work, $name being "<{dir}/>{title}".
+
+const (
+	DefaultDirRule = "{title}"
+)
+
+var (
+	dirRuleFlag = flag.String("dir-rule", "", "show directory naming rule (default "+DefaultDirRule+")")
+	dirRule     = DefaultDirRule
+
+	episodeVersionRegex = regexp.MustCompile(`[vV](\d{1,2})$`)
+)
+
+// getReleaseFields returns the template fields of the release tags.
+func getReleaseFields(r *release.ParsedRelease) map[string]string {
+	fields := map[string]string{
+		"group":      r.Group,
+		"resolution": r.Resolution,
+		"source":     r.Source,
+		"codec":      r.VideoCodec,
+		"crc":        r.CRC32,
+	}
+
+	if r.Year > 0 {
+		fields["year"] = strconv.Itoa(r.Year)
+	}
+	if r.Version > 0 {
+		fields["version"] = strconv.Itoa(r.Version)
+	}
+
+	return fields
+}
+
+// getSeasonNumber returns the season number of a season directory, "0" for specials.
+func getSeasonNumber(dir string) string {
+	if dir == parser.SpecialsDir {
+		return "0"
+	}
+
+	if isSpecialDir(dir) {
+		return ""
+	}
+
+	number := regexp.MustCompile(`\d{1,3}`).FindString(dir)
+	if number == "" {
+		return ""
+	}
+
+	n, _ := strconv.Atoi(number)
+	return strconv.Itoa(n)
+}
+
+// getNameFields collects the template fields of one file.
+// video is the probed or edited name with its directory, origName is the source file name.
+func getNameFields(video, episode, origName, releaseName string) map[string]string {
+	fields := getReleaseFields(parser.Parse(origName))
+	for key, value := range getReleaseFields(parser.Parse(releaseName)) {
+		if fields[key] == "" {
+			fields[key] = value
+		}
+	}
+
+	name, ext := getExtName(video)
+	dir, title := getSplitPath(name)
+	lastExt := path.Ext(ext)
+
+	fields["title"] = strings.TrimSpace(title)
+	fields["dir"] = dir
+	fields["season"] = getSeasonNumber(dir)
+	//the version has its own placeholder, 02v2 => 02
+	if match := episodeVersionRegex.FindStringSubmatch(episode); match != nil {
+		episode = episode[:len(episode)-len(match[0])]
+		if fields["version"] == "" {
+			fields["version"] = match[1]
+		}
+	}
+	fields["episode"] = episode
+	fields["ext"] = lastExt
+	fields["lang"] = strings.TrimPrefix(ext[:len(ext)-len(lastExt)], ".")
+
+	return fields
+}
+
+func getTemplateValue(placeholder string, fields map[string]string) string {
+	name := placeholder
+	width := 0
+	if index := strings.Index(placeholder, ":"); index >= 0 {
+		name = placeholder[:index]
+		width, _ = strconv.Atoi(placeholder[index+1:])
+	}
+
+	value := fields[strings.TrimSpace(name)]
+
+	//pad the integer part only, 3.5 => 03.5, NCOP1 stays as is
+	if width > 0 && value != "" && value[0] >= '0' && value[0] <= '9' {
+		digits := len(value)
+		if index := strings.IndexFunc(value, func(r rune) bool { return r < '0' || r > '9' }); index >= 0 {
+			digits = index
+		}
+		if digits < width {
+			value = strings.Repeat("0", width-digits) + value
+		}
+	}
+
+	return value
+}
+
+// renderTemplate fills the placeholders of a naming rule.
+func renderTemplate(rule string, fields map[string]string) string {
+	rule = strings.ReplaceAll(rule, NameReplaceStr, "<{dir}/>{title}")
+	rule = strings.ReplaceAll(rule, EpisodeReplaceStr, "{episode}")
+
+	var out, section strings.Builder
+	inSection := false
+	sectionEmpty := false
+
+	write := func(str string) {
+		if inSection {
+			section.WriteString(str)
+		} else {
+			out.WriteString(str)
+		}
+	}
+
+	for i := 0; i < len(rule); i++ {
+		c := rule[i]
+
+		switch {
+		case c == '<' && !inSection:
+			inSection = true
+			sectionEmpty = false
+			section.Reset()
+		case c == '>' && inSection:
+			if !sectionEmpty {
+				out.WriteString(section.String())
+			}
+			inSection = false
+		case c == '{':
+			end := strings.IndexByte(rule[i:], '}')
+			if end < 0 {
+				write(rule[i:])
+				i = len(rule)
+				continue
+			}
+
+			value := getTemplateValue(rule[i+1:i+end], fields)
+			if value == "" {
+				sectionEmpty = true
+			}
+			write(value)
+			i += end
+		default:
+			write(rule[i : i+1])
+		}
+	}
+
+	//an unclosed section is kept like a closed one
+	if inSection && !sectionEmpty {
+		out.WriteString(section.String())
+	}
+
+	return cleanPath(out.String())
+}
+
+// cleanPath trims the spaces around every element of a rendered path.
+func cleanPath(name string) string {
+	parts := strings.Split(name, "/")
+	cleaned := make([]string, 0, len(parts))
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		if part != "" {
+			cleaned = append(cleaned, part)
+		}
+	}
+
+	return strings.Join(cleaned, "/")
+}
+
+// getShowDirName names the directory of a show or movie from its release name.
+func getShowDirName(releaseName string) string {
+	title := probeTitle(releaseName)
+	title = strings.TrimSpace(title)
+	if title == "" {
+		title = "Unknown"
+	}
+
+	fields := getReleaseFields(parser.Parse(releaseName))
+	fields["title"] = title
+
+	name := renderTemplate(dirRule, fields)
+	if name == "" {
+		return title
+	}
+
+	return name
+}
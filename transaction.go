@@ -0,0 +1,265 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// linkTx is the set of links of one directory, which are created all or none.
+type linkTx struct {
+	links    []string //created links, in order
+	dirs     []string //created directories, parents first
+	replaced []string //files replaced by overwrite...
+	backups  []string //...and where they are kept until the commit
+	linkMode string   //overrides -link-mode, like when relinking with the journaled mode
+}
+
+var failedDirs []string
+
+// getRootDir returns where the missing directories of file are created,
+// the working directory for relative paths.
+func getRootDir(file string) string {
+	if strings.HasPrefix(file, "/") {
+		return "/"
+	}
+
+	return "."
+}
+
+// validateEntries checks the links of a directory before any of them is made.
+func validateEntries(entries []LinkEntry) error {
+	destinations := make(map[string]string)
+	dirs := make(map[string]bool)
+	var inodes, bytes uint64
+
+	for _, entry := range entries {
+		if entry.Skipped {
+			continue
+		}
+
+		info, err := os.Stat(entry.Source)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return fmt.Errorf("%s is a directory", entry.Source)
+		}
+
+		//suffixes tell two files with the same name apart, or one is an upgrade of the other,
+		//the other policies lose one of them
+		if source, ok := destinations[entry.Destination]; ok && source != entry.Source &&
+			*onCollisionFlag != CollisionSuffix && checkUpgrade(source, entry.Source) == 0 {
+			return fmt.Errorf("both %s and %s would be linked to %s", source, entry.Source, entry.Destination)
+		}
+		destinations[entry.Destination] = entry.Source
+
+		if _, err := os.Lstat(entry.Destination); err == nil {
+			if *onCollisionFlag == CollisionFail && !checkLinked(entry.Source, entry.Destination) &&
+				checkUpgrade(getJournaledSource(entry.Destination), entry.Source) == 0 {
+				return fmt.Errorf("%s exists", entry.Destination)
+			}
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+
+		//hard links take no inode nor space, the other modes do
+		if *linkModeFlag != LinkHard {
+			inodes++
+		}
+		if *linkModeFlag == LinkCopy {
+			bytes += uint64(info.Size())
+		}
+
+		dir, _ := getSplitPath(entry.Destination)
+		for ; dir != ""; dir, _ = getSplitPath(dir) {
+			exists, err := statFileExists(dir)
+			if err != nil {
+				return err
+			}
+			if exists {
+				break
+			}
+
+			if !dirs[dir] {
+				dirs[dir] = true
+				inodes++
+			}
+		}
+		if dir == "" {
+			dir = getRootDir(entry.Destination)
+		}
+		dirs[dir] = true
+	}
+
+	for dir := range dirs {
+		if exists, err := statFileExists(dir); err != nil {
+			return err
+		} else if !exists {
+			continue
+		}
+
+		if err := checkWritable(dir); err != nil {
+			return err
+		}
+	}
+
+	if common := getCommonDir(entries); common != "" {
+		for common != "" {
+			exists, err := statFileExists(common)
+			if err != nil {
+				return err
+			}
+			if exists {
+				break
+			}
+			common, _ = getSplitPath(common)
+		}
+		if common == "" {
+			common = getRootDir(entries[0].Destination)
+		}
+
+		if err := checkFreeSpace(common, inodes, bytes); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rollback removes what was created by tx and puts replaced files back.
+func (tx *linkTx) rollback() {
+	replaced := make(map[string]bool)
+	for _, file := range tx.replaced {
+		replaced[file] = true
+	}
+
+	for i := len(tx.links) - 1; i >= 0; i-- {
+		//replaced files are renamed back over their link, it is not removed before
+		if !replaced[tx.links[i]] {
+			if err := os.Remove(tx.links[i]); err != nil && !os.IsNotExist(err) {
+				fmt.Printf("Cannot remove %s: %s.\n", tx.links[i], err.Error())
+				continue
+			}
+		}
+
+		if err := writeJournal(JournalRecord{
+			Action:      JournalRollback,
+			Destination: getAbsPath(tx.links[i]),
+		}); err != nil {
+			fmt.Printf("Cannot journal the removal of %s: %s.\n", tx.links[i], err.Error())
+		}
+	}
+
+	for i := len(tx.backups) - 1; i >= 0; i-- {
+		if err := os.Rename(tx.backups[i], tx.replaced[i]); err != nil {
+			fmt.Printf("Cannot restore %s: %s.\n", tx.replaced[i], err.Error())
+		}
+	}
+
+	for i := len(tx.dirs) - 1; i >= 0; i-- {
+		if empty, err := statDirEmpty(tx.dirs[i]); err != nil || !empty {
+			continue
+		}
+
+		if err := os.Remove(tx.dirs[i]); err != nil {
+			fmt.Printf("Cannot remove %s: %s.\n", tx.dirs[i], err.Error())
+			continue
+		}
+
+		if err := writeJournal(JournalRecord{
+			Action:      JournalRollback,
+			Destination: getAbsPath(tx.dirs[i]),
+		}); err != nil {
+			fmt.Printf("Cannot journal the removal of %s: %s.\n", tx.dirs[i], err.Error())
+		}
+	}
+}
+
+// commit drops the replaced files.
+func (tx *linkTx) commit() {
+	for _, backup := range tx.backups {
+		os.Remove(backup)
+	}
+}
+
+// linkEntries links the entries of one directory, or none of them if any link fails.
+func linkEntries(entries []LinkEntry) error {
+	if err := validateEntries(entries); err != nil {
+		return err
+	}
+
+	tx := &linkTx{}
+	for _, entry := range entries {
+		if entry.Skipped {
+			continue
+		}
+
+		if err := tx.linkFile(entry.Source, entry.Destination); err != nil {
+			tx.rollback()
+			return err
+		}
+	}
+
+	tx.commit()
+	return nil
+}
+
+// linkDirEntries links the entries of dir, reporting a failure instead of exiting.
+func linkDirEntries(dir string, entries []LinkEntry) {
+	if *verifyCRCFlag {
+		verifyEntries(entries)
+	}
+
+	if err := linkEntries(entries); err != nil {
+		fmt.Printf("[FAILED] %s: %s. Nothing linked.\n", dir, err.Error())
+		failedDirs = append(failedDirs, dir)
+	}
+}
+
+// groupEntries splits the entries of a plan by source directory, keeping their order.
+func groupEntries(entries []LinkEntry) (dirs []string, groups [][]LinkEntry) {
+	index := make(map[string]int)
+	for _, entry := range entries {
+		dir, _ := getSplitPath(entry.Source)
+		//companions in subfolders are linked with their video
+		if entry.Video != "" {
+			dir, _ = getSplitPath(entry.Video)
+		}
+
+		i, ok := index[dir]
+		if !ok {
+			i = len(dirs)
+			index[dir] = i
+			dirs = append(dirs, dir)
+			groups = append(groups, nil)
+		}
+
+		groups[i] = append(groups[i], entry)
+	}
+
+	return dirs, groups
+}
+
+// reportFailures lists the directories that failed to link and the videos refused
+// by the crc check, and exits if there are any.
+func reportFailures() {
+	if len(failedDirs) == 0 && len(badCRCFiles) == 0 {
+		return
+	}
+
+	if len(failedDirs) > 0 {
+		fmt.Printf("%d directories failed to link:\n", len(failedDirs))
+		for _, dir := range failedDirs {
+			fmt.Printf("  %s\n", dir)
+		}
+	}
+
+	if len(badCRCFiles) > 0 {
+		fmt.Printf("%d videos failed the crc check:\n", len(badCRCFiles))
+		for _, file := range badCRCFiles {
+			fmt.Printf("  %s\n", file)
+		}
+	}
+	os.Exit(1)
+}
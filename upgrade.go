@@ -0,0 +1,107 @@
+package main
+
+import (
+	"flag"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/xsm1997/animeLinker/release"
+)
+
+var (
+	upgradeFlag = flag.Bool("upgrade", true, "replace links of older versions or worse releases of an episode")
+
+	//higher is better, unknown sources are not compared
+	sourceRanks = map[string]int{
+		"BDMV": 3, "BD": 3, "BluRay": 3, "BDRip": 3,
+		"WEB-DL": 2, "WEBRip": 2, "WEB": 2,
+		"DVD": 1, "DVDRip": 1,
+		"HDTV": 0, "TVRip": 0,
+	}
+)
+
+// parseSource parses a source file, taking the tags missing in its name from its directory.
+func parseSource(file string) *release.ParsedRelease {
+	dir, name := getSplitPath(file)
+	r := parser.Parse(name)
+
+	_, dirName := getSplitPath(dir)
+	if dirName == "" {
+		return r
+	}
+
+	d := parser.Parse(dirName)
+	if r.Group == "" {
+		r.Group = d.Group
+	}
+	if r.Resolution == "" {
+		r.Resolution = d.Resolution
+	}
+	if r.Source == "" {
+		r.Source = d.Source
+	}
+
+	return r
+}
+
+func getResolutionRank(resolution string) int {
+	rank, _ := strconv.Atoi(strings.TrimRight(resolution, "pi"))
+	return rank
+}
+
+// getGroupRank returns the place of group in the preferred groups, lower is better.
+func getGroupRank(group string) int {
+	for i, preferred := range config.Upgrade.Groups {
+		if strings.EqualFold(group, preferred) {
+			return i
+		}
+	}
+
+	return len(config.Upgrade.Groups)
+}
+
+// compareReleases tells if newRelease is better than oldRelease (> 0), worse (< 0) or neither.
+// The rules are checked in order: version of the same group, resolution, source, preferred groups.
+func compareReleases(oldRelease, newRelease *release.ParsedRelease) int {
+	if strings.EqualFold(oldRelease.Group, newRelease.Group) {
+		//no version is v1
+		oldVersion, newVersion := oldRelease.Version, newRelease.Version
+		if oldVersion == 0 {
+			oldVersion = 1
+		}
+		if newVersion == 0 {
+			newVersion = 1
+		}
+		if oldVersion != newVersion {
+			return newVersion - oldVersion
+		}
+	}
+
+	if oldRank, newRank := getResolutionRank(oldRelease.Resolution), getResolutionRank(newRelease.Resolution); oldRank > 0 && newRank > 0 && oldRank != newRank {
+		return newRank - oldRank
+	}
+
+	oldRank, ok1 := sourceRanks[oldRelease.Source]
+	newRank, ok2 := sourceRanks[newRelease.Source]
+	if ok1 && ok2 && oldRank != newRank {
+		return newRank - oldRank
+	}
+
+	return getGroupRank(oldRelease.Group) - getGroupRank(newRelease.Group)
+}
+
+// checkUpgrade tells if the link of oldSource is to be replaced by a link of newSource (> 0),
+// if newSource is a worse release (< 0), or if upgrade rules do not apply (0).
+func checkUpgrade(oldSource, newSource string) int {
+	if !*upgradeFlag || oldSource == "" || oldSource == getAbsPath(newSource) {
+		return 0
+	}
+
+	//only another release of the same file, not a subtitle replacing a video
+	if path.Ext(oldSource) != path.Ext(newSource) {
+		return 0
+	}
+
+	return compareReleases(parseSource(oldSource), parseSource(newSource))
+}
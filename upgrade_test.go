@@ -0,0 +1,84 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestCheckUpgrade(t *testing.T) {
+	in := [][]string{
+		{`/src/[Grp] Show/[Grp] Show - 01 [1080p].mkv`, `/src/[Grp] Show/[Grp] Show - 01v2 [1080p].mkv`},
+		{`/src/[Grp] Show/[Grp] Show - 01v2 [1080p].mkv`, `/src/[Grp] Show/[Grp] Show - 01 [1080p].mkv`},
+		{`/src/[Grp] Show [720p]/[Grp] Show - 01.mkv`, `/src/[Other] Show [1080p]/[Other] Show - 01.mkv`},
+		{`/src/[Grp] Show [WEB-DL 1080p]/[Grp] Show - 01.mkv`, `/src/[Other] Show [BDRip 1080p]/[Other] Show - 01.mkv`},
+		{`/src/[Grp] Show/[Grp] Show - 01.mkv`, `/src/[Other] Show/[Other] Show - 01.mkv`},
+		{`/src/[Grp] Show/[Grp] Show - 01.mkv`, `/src/[Grp] Show/[Grp] Show - 01.sc.ass`},
+	}
+
+	out1 := []bool{true, false, true, true, false, false}
+
+	for i, data := range in {
+		o1 := out1[i]
+		r1 := checkUpgrade(data[0], data[1]) > 0
+
+		if r1 != o1 {
+			t.Errorf("Data %s => %s: excepted %t, got %t", data[0], data[1], o1, r1)
+		}
+	}
+}
+
+func TestUndoUpgrade(t *testing.T) {
+	dir, err := ioutil.TempDir("", "animelinker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	journalDir = dir
+	liveLinks = nil
+	defer func(id string) {
+		journalFile.Close()
+		journalFile = nil
+		liveLinks = nil
+		runID = id
+	}(runID)
+
+	in := []string{`[Grp] Show - 01 [1080p].mkv`, `[Grp] Show - 01v2 [1080p].mkv`}
+	newPath := path.Join(dir, "Show/S01/Show - 01.mkv")
+
+	for i, name := range in {
+		source := path.Join(dir, name)
+		ioutil.WriteFile(source, []byte(name), 0644)
+
+		//every run links one release, the second one upgrades the link
+		runID = name
+		tx := &linkTx{linkMode: LinkCopy}
+		if err := tx.linkFile(source, newPath); err != nil {
+			t.Fatal(err)
+		}
+		tx.commit()
+
+		if data, _ := ioutil.ReadFile(newPath); string(data) != in[i] {
+			t.Errorf("Data %s: excepted %s, got %s", name, in[i], data)
+		}
+	}
+
+	if err := undoRun(dir, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, _ := ioutil.ReadFile(newPath); string(data) != in[0] {
+		t.Errorf("Data %s: excepted %s, got %s", newPath, in[0], data)
+	}
+
+	records, _ := readJournal(dir)
+	links, _ := getLiveLinks(records)
+	if r1 := links[newPath].Source; r1 != path.Join(dir, in[0]) {
+		t.Errorf("Data %s: excepted %s, got %s", newPath, path.Join(dir, in[0]), r1)
+	}
+	if r1 := lastRunID(records); r1 != in[0] {
+		t.Errorf("Data %s: excepted %s, got %s", "last run", in[0], r1)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+)
+
+var relinkFlag = flag.Bool("relink", false, "verify: link broken entries again")
+
+// checkLinkRecord tells what is wrong with a journaled link, or "" if it is intact.
+// The second result tells if the link can be made again from its source.
+func checkLinkRecord(record JournalRecord) (string, bool) {
+	sourceInfo, err := os.Stat(record.Source)
+	if err != nil {
+		return "source vanished", false
+	}
+
+	destInfo, err := os.Lstat(record.Destination)
+	if err != nil {
+		//maybe on purpose, it is not linked again
+		return "removed from the library", false
+	}
+
+	if _, ino, ok := getFileID(destInfo); ok && record.Inode != 0 && ino != record.Inode {
+		return "replaced in the library", false
+	}
+
+	switch record.LinkMode {
+	case LinkSym, LinkRelSym:
+		target, err := os.Stat(record.Destination)
+		if err != nil {
+			return "dangling symlink", true
+		}
+		if !os.SameFile(sourceInfo, target) {
+			return "symlink points elsewhere", true
+		}
+	case LinkCopy, LinkReflink:
+		//copies keep the size and modification time of their source
+		if sourceInfo.Size() != destInfo.Size() {
+			return "source changed size", true
+		}
+		if !sourceInfo.ModTime().Equal(destInfo.ModTime()) {
+			return "source changed", true
+		}
+	default:
+		if os.SameFile(sourceInfo, destInfo) {
+			break
+		}
+
+		//a client re-downloading or re-checking a file writes a new inode
+		if sourceInfo.Size() != destInfo.Size() {
+			return "source changed size, no longer linked", true
+		}
+		return "source replaced, no longer linked", true
+	}
+
+	return "", false
+}
+
+// relinkRecord links the source of record to its destination again, with the journaled link mode.
+func relinkRecord(record JournalRecord) error {
+	tx := &linkTx{linkMode: record.LinkMode}
+
+	dir, _ := getSplitPath(record.Destination)
+	dirs, err := mkdirAll(dir)
+	tx.dirs = append(tx.dirs, dirs...)
+	if err == nil {
+		if _, err2 := os.Lstat(record.Destination); os.IsNotExist(err2) {
+			err = tx.makeLink(record.Source, record.Destination)
+		} else {
+			err = tx.replaceLink(record.Source, record.Destination)
+		}
+	}
+
+	if err != nil {
+		tx.rollback()
+		return err
+	}
+
+	tx.commit()
+	return nil
+}
+
+// verifyDir checks that the files linked into dst still share their data with their sources.
+// It returns the number of problems left.
+func verifyDir(dst string) (int, error) {
+	dst = getAbsPath(dst)
+
+	records, err := readJournal(dst)
+	if os.IsNotExist(err) {
+		//without journal, only files with no other link can be told
+		fmt.Printf("No journal in %s, looking for files with no other link.\n", dst)
+
+		orphans, err := findOrphans(dst)
+		if err != nil {
+			return 0, err
+		}
+
+		for _, o := range orphans {
+			fmt.Printf("[UNLINKED] %s: %s\n", o.path, o.reason)
+		}
+		return len(orphans), nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	links, _ := getLiveLinks(records)
+	destinations := make([]string, 0, len(links))
+	for destination := range links {
+		destinations = append(destinations, destination)
+	}
+	sort.Strings(destinations)
+
+	problems := 0
+	for _, destination := range destinations {
+		record := links[destination]
+
+		problem, fixable := checkLinkRecord(record)
+		if problem == "" {
+			continue
+		}
+
+		if *relinkFlag && fixable {
+			if err := relinkRecord(record); err != nil {
+				fmt.Printf("[BROKEN] %s: %s, cannot relink: %s.\n", destination, problem, err.Error())
+				problems++
+			} else {
+				fmt.Printf("[RELINKED] %s: %s.\n", destination, problem)
+			}
+			continue
+		}
+
+		fmt.Printf("[BROKEN] %s: %s.\n", destination, problem)
+		problems++
+	}
+
+	fmt.Printf("%d links checked, %d problems.\n", len(destinations), problems)
+	return problems, nil
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	settleTime   = flag.Duration("settle", time.Minute, "watch: time a release must stay unchanged before linking")
+	pollInterval = flag.Duration("interval", 10*time.Second, "watch: polling interval")
+
+	//a release containing one of these is still being downloaded
+	partialSuffix = []string{
+		".!qB",
+		".!qb",
+		".part",
+		".aria2",
+		".!ut",
+		".crdownload",
+	}
+)
+
+// releaseState is the last seen state of a top level entry of the watched directory.
+type releaseState struct {
+	size       int64
+	files      int
+	modTime    time.Time
+	partial    bool
+	lastChange time.Time
+	linked     bool
+}
+
+func (s *releaseState) sameAs(s2 *releaseState) bool {
+	return s.size == s2.size && s.files == s2.files && s.modTime.Equal(s2.modTime) && s.partial == s2.partial
+}
+
+func isPartialFile(name string) bool {
+	for _, suffix := range partialSuffix {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getReleaseState sums up size and modify time of a release file or directory.
+func getReleaseState(release string) (*releaseState, error) {
+	state := &releaseState{}
+
+	err := filepath.Walk(release, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		state.files++
+		state.size += info.Size()
+		if info.ModTime().After(state.modTime) {
+			state.modTime = info.ModTime()
+		}
+		if isPartialFile(info.Name()) {
+			state.partial = true
+		}
+
+		return nil
+	})
+
+	return state, err
+}
+
+func listReleases(src string) []string {
+	file, err := os.Open(src)
+	if err != nil {
+		fmt.Printf("Cannot read dir %s. error: %s.\n", src, err.Error())
+		return []string{}
+	}
+	defer file.Close()
+
+	names, err := file.Readdirnames(-1)
+	if err != nil {
+		fmt.Printf("Cannot read dir %s. error: %s.\n", src, err.Error())
+		return []string{}
+	}
+
+	releases := make([]string, 0, len(names))
+	for _, name := range names {
+		if !strings.HasPrefix(name, ".") {
+			releases = append(releases, name)
+		}
+	}
+
+	return releases
+}
+
+// watchDir links every release appearing in src once it stopped changing.
+// Releases already in src when watching starts are left alone.
+func watchDir(src, dst string) {
+	changes := make(chan string, 64)
+
+	if err := startNotify(src, changes); err != nil {
+		fmt.Printf("inotify unavailable (%s), polling every %s.\n", err.Error(), pollInterval.String())
+	} else {
+		fmt.Printf("Watching %s with inotify.\n", src)
+	}
+
+	states := make(map[string]*releaseState)
+	for _, name := range listReleases(src) {
+		state, err := getReleaseState(path.Join(src, name))
+		if err != nil {
+			continue
+		}
+		state.linked = true
+		states[name] = state
+	}
+
+	ticker := time.NewTicker(*pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case name := <-changes:
+			if name != "" {
+				noteChange(src, name, states)
+				continue
+			}
+			//events were lost, rescan everything now
+			fmt.Println("inotify queue overflowed, rescanning.")
+		case <-ticker.C:
+		}
+
+		scanReleases(src, dst, states)
+	}
+}
+
+// noteChange delays the linking of a release changed by an event.
+// Whether it is linked again is decided by the next scan.
+func noteChange(src, name string, states map[string]*releaseState) {
+	if state, ok := states[name]; ok {
+		state.lastChange = time.Now()
+		return
+	}
+
+	state, err := getReleaseState(path.Join(src, name))
+	if err != nil {
+		return
+	}
+	state.lastChange = time.Now()
+	states[name] = state
+	fmt.Printf("New release %s found.\n", name)
+}
+
+// scanReleases compares the releases of src with their last state,
+// and links the ones which stopped changing.
+func scanReleases(src, dst string, states map[string]*releaseState) {
+	now := time.Now()
+	releases := listReleases(src)
+
+	//forget removed releases, so that a re-download gets linked again
+	present := make(map[string]bool, len(releases))
+	for _, name := range releases {
+		present[name] = true
+	}
+	for name := range states {
+		if !present[name] {
+			delete(states, name)
+		}
+	}
+
+	for _, name := range releases {
+		state, err := getReleaseState(path.Join(src, name))
+		if err != nil {
+			//removed or unreadable while scanning, try again next tick
+			continue
+		}
+
+		old, ok := states[name]
+		if !ok {
+			state.lastChange = now
+			states[name] = state
+			fmt.Printf("New release %s found.\n", name)
+			continue
+		}
+
+		if !state.sameAs(old) {
+			state.lastChange = now
+			states[name] = state
+			continue
+		}
+
+		if old.linked || old.partial || now.Sub(old.lastChange) < *settleTime {
+			continue
+		}
+
+		fmt.Printf("Release %s completed, linking.\n", name)
+		probeRelease(path.Join(src, name), dst)
+		old.linked = true
+	}
+}